@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// Actions recorded in the audit log.
+const (
+	auditLoginSuccess           = "login.success"
+	auditLoginFailure           = "login.failure"
+	auditLogout                 = "logout"
+	auditPasswordChange         = "password.change"
+	auditSignup                 = "user.signup"
+	auditSnippetCreate          = "snippet.create"
+	auditAdminUserActivate      = "admin.user.activate"
+	auditAdminUserDeactivate    = "admin.user.deactivate"
+	auditAdminUserResetPassword = "admin.user.reset_password"
+	auditAdminSnippetHide       = "admin.snippet.hide"
+	auditAdminSnippetUnhide     = "admin.snippet.unhide"
+	auditAdminSnippetDelete     = "admin.snippet.delete"
+	auditAdminAuditExport       = "admin.audit.export"
+)
+
+// auditActions lists all the actions, in the order shown in the audit log filter.
+var auditActions = []string{
+	auditLoginSuccess,
+	auditLoginFailure,
+	auditLogout,
+	auditPasswordChange,
+	auditSignup,
+	auditSnippetCreate,
+	auditAdminUserActivate,
+	auditAdminUserDeactivate,
+	auditAdminUserResetPassword,
+	auditAdminSnippetHide,
+	auditAdminSnippetUnhide,
+	auditAdminSnippetDelete,
+	auditAdminAuditExport,
+}
+
+// recordAudit appends an event of given action on the target to the audit log. The actor
+// is the user logged in the session of r, if any. Failing to record the event is only
+// logged, so that it never breaks the request itself.
+func (app *application) recordAudit(r *http.Request, action, target string) {
+	err := app.auditEvents.Insert(&models.AuditEvent{
+		ActorID:   app.session.GetInt(r, "authenticatedUserID"),
+		Action:    action,
+		Target:    target,
+		IP:        remoteIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		app.log(r).Error("Failed to record audit event", "action", action, "target", target, "error", err.Error())
+	}
+}
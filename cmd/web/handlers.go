@@ -3,14 +3,33 @@ package main
 import (
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/forms"
 	"kerseeeHuang.com/snippetbox/pkg/models"
 )
 
+// markdownCacheSize is the number of Markdown snippets whose rendered HTML is cached.
+const markdownCacheSize = 1000
+
+// maxTagsPerSnippet is the maximum number of tags on a snippet.
+const maxTagsPerSnippet = 5
+
+// profilePageSize is the number of snippets shown on each page of a public profile.
+const profilePageSize = 10
+
+// mostStarredSize is the number of snippets shown in the most starred section on the home page.
+const mostStarredSize = 5
+
+// tagCloudSize is the number of tags shown in the tag cloud on the home page.
+const tagCloudSize = 30
+
 // neuteredFileSystem is a wrapper of http.FileSystem to prevent listing files
 // in directories without index.html.
 type neuteredFileSystem struct {
@@ -53,15 +72,56 @@ func ping(w http.ResponseWriter, r *http.Request) {
 // home is a handler function which renders the home page.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// Show the latest snippets in the database.
-	s, err := app.snippets.Latest()
+	s, err := app.snippets.Latest(r.Context(), "", listSort(r))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Show the snippets which received the most stars in the last week.
+	starred, err := app.stars.MostStarredSince(r.Context(), time.Now().AddDate(0, 0, -7), mostStarredSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Show the popular tags as a tag cloud.
+	tags, err := app.tags.Popular(tagCloudSize)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	// Render the html with template and data.
 	app.render(w, r, "home.page.tmpl", &templateData{
+		Feed:        "/feed",
+		MostStarred: starred,
+		Snippets:    s,
+		Sort:        listSort(r),
+		Tags:        tags,
+	})
+}
+
+// tagSnippets is a handler function which shows the latest snippets with a specific tag.
+func (app *application) tagSnippets(w http.ResponseWriter, r *http.Request) {
+	// A tag which can never be created has no snippets.
+	tag := r.URL.Query().Get(":tag")
+	if !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+
+	s, err := app.snippets.Latest(r.Context(), tag, listSort(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "tag.page.tmpl", &templateData{
+		Feed:     "/tags/" + tag + "/feed",
 		Snippets: s,
+		Sort:     listSort(r),
+		Tag:      tag,
 	})
 }
 
@@ -70,7 +130,8 @@ func (app *application) about(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "about.page.tmpl", &templateData{})
 }
 
-// showSnippet is a handler function which shows a specific snippet.
+// showSnippet is a handler function which shows a specific snippet as an HTML page, JSON
+// or plain text, according to the Accept header.
 func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 	// Extract the id in URL and parse to int.
 	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
@@ -82,57 +143,274 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 
 	// Get data via SnippetModel connected to the database based on given id.
 	// If no matching record is found, return a 404 Not Found response.
-	s, err := app.snippets.Get(id)
+	s, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// Pretend that a private snippet does not exist for anyone but its owner.
+	if !app.canViewSnippet(r, s) {
+		app.notFound(w)
+		return
+	}
+
+	app.viewCounter.Record(s.ID, app.viewer(r), time.Now())
+
+	// Scripts get the same snippet as JSON or as the raw content of its first file.
+	switch negotiate(w, r, mediaHTML, mediaJSON, mediaText) {
+	case mediaJSON:
+		app.writeJSON(w, r, http.StatusOK, app.newSnippetJSON(s))
+	case mediaText:
+		app.writeText(w, s.Content)
+	default:
+		app.renderSnippet(w, r, s, forms.New(nil))
+	}
+}
+
+// renderSnippet renders the page of the snippet with its stars, forks, comments and the
+// collections of the user, and the given form for a new comment.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) {
+	stars, err := app.stars.Count(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The views not written to the database yet count as well.
+	views, err := app.views.Total(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	views += app.viewCounter.Pending(s.ID)
+
+	forks, err := app.snippets.Forks(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Check whether the current user has starred this snippet, and find the collections
+	// of the user to which it can be added.
+	starred := false
+	var collections []*models.Collection
+	if app.isAuthenticated(r) {
+		userID := app.session.GetInt(r, "authenticatedUserID")
+		starred, err = app.stars.IsStarred(r.Context(), userID, s.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		collections, err = app.collections.ByUser(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	comments, err := app.comments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var content template.HTML
+	if s.ContentType == models.ContentTypeMarkdown {
+		content, err = app.markdown.Render(s.ID, s.Revision, s.Content)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	oembed := ""
+	if s.Visibility != models.VisibilityPrivate {
+		oembed = "/oembed?url=" + url.QueryEscape(app.absoluteURL(fmt.Sprintf("/snippet/%d", s.ID)))
+	}
+
 	// Render the html with template and data.
 	app.render(w, r, "show.page.tmpl", &templateData{
-		Snippet: s,
+		Collections:     collections,
+		Comments:        comments,
+		Forks:           forks,
+		Form:            form,
+		OEmbed:          oembed,
+		RenderedContent: content,
+		Snippet:         s,
+		Stars:           stars,
+		Starred:         starred,
+		Views:           views,
+	})
+}
+
+// starSnippet stars a specific snippet for the current user.
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.stars.Star(r.Context(), app.session.GetInt(r, "authenticatedUserID"), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// unstarSnippet removes the star of the current user from a specific snippet.
+func (app *application) unstarSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.stars.Unstar(r.Context(), app.session.GetInt(r, "authenticatedUserID"), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// userStars shows the snippets starred by the current user.
+func (app *application) userStars(w http.ResponseWriter, r *http.Request) {
+	s, err := app.stars.ByUser(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "stars.page.tmpl", &templateData{
+		Snippets: s,
 	})
 }
 
 // createSnippetForm create the form for client to create a snippet.
 func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
 	// Render a blank form.
-	app.render(w, r, "create.page.tmpl", &templateData{
-		Form: forms.New(nil),
-	})
+	app.renderSnippetForm(w, r, forms.New(url.Values{}))
+}
+
+// forkSnippetForm shows the form for creating a snippet pre-filled with a copy
+// of a specific snippet.
+func (app *application) forkSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("title", s.Title)
+	for _, f := range s.Files {
+		form.Add("file_name", f.Name)
+		form.Add("file_content", f.Content)
+	}
+	form.Set("content_type", s.ContentType)
+	form.Set("tags", strings.Join(s.Tags, ", "))
+	form.Set("visibility", s.Visibility)
+	form.Set("forked_from", strconv.Itoa(s.ID))
+
+	app.renderSnippetForm(w, r, form)
 }
 
-// showSnippet is a handler function which creates a specific snippet and store it into DB.
+// createSnippet is a handler function which creates a snippet from the posted form and store it into DB.
 func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// Parse the from in the request and store it in r.PostForm.
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
+		return
 	}
 
-	// Retrieve data in the r.PostForm and validate the data
+	// Retrieve data in the r.PostForm. A single file may still be posted as "content".
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
+	if _, ok := form.Values["file_content"]; !ok && form.Get("content") != "" {
+		form.Values["file_name"] = []string{""}
+		form.Values["file_content"] = []string{form.Get("content")}
+	}
+
+	// The buttons to add or remove a file only redisplay the form with the change.
+	if form.Get("add_file") != "" || form.Get("remove_file") != "" {
+		editFormFiles(form)
+		app.renderSnippetForm(w, r, form)
+		return
+	}
+
+	// Validate the data.
+	form.Required("title", "expires", "visibility")
 	form.MaxLength("title", 100)
 	form.PermittedValues("expires", "365", "7", "1")
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	form.PermittedValues("content_type", models.ContentTypeText, models.ContentTypeMarkdown)
+	form.ValidTags("tags", maxTagsPerSnippet)
+	form.ValidFiles("file_name", "file_content", maxFilesPerSnippet)
+
+	// Only a snippet visible to the user can be forked.
+	forkedFrom := 0
+	if v := form.Get("forked_from"); v != "" {
+		parentID, err := strconv.Atoi(v)
+		if err != nil || parentID < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		parent, err := app.snippets.Get(r.Context(), parentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		if err != nil || !app.canViewSnippet(r, parent) {
+			form.Errors.Add("generic", "The snippet you are forking no longer exists")
+		} else {
+			forkedFrom = parent.ID
+		}
+	}
 
 	// Redisplay the template and filled-in data if the form is not valid.
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		app.renderSnippetForm(w, r, form)
 		return
 	}
 
+	// Snippets are plain text unless told otherwise.
+	contentType := form.Get("content_type")
+	if contentType == "" {
+		contentType = models.ContentTypeText
+	}
+
+	// A single file may be left unnamed.
+	files := formFiles(form)
+	if files[0].Name == "" {
+		files[0].Name = models.DefaultFileName(contentType)
+	}
+
 	// Create a new snippet in db and get back the id of the new record.
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	id, err := app.snippets.Insert(r.Context(), &models.Snippet{
+		Title:       form.Get("title"),
+		Content:     files[0].Content,
+		Files:       files,
+		ContentType: contentType,
+		Tags:        form.Tags("tags"),
+		UserID:      app.session.GetInt(r, "authenticatedUserID"),
+		Visibility:  form.Get("visibility"),
+		ForkedFrom:  forkedFrom,
+	}, form.Get("expires"))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	app.recordAudit(r, auditSnippetCreate, fmt.Sprintf("snippet:%d", id))
+	app.metrics.snippetsCreated.Inc()
+	app.notifySnippet(r.Context(), webhookSnippetCreated, id)
+
 	// Add session data to show flash information.
 	app.session.Put(r, "flash", "Snippet successfully created!")
 
@@ -170,17 +448,20 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create an user if it is valid. Otherwise redisplay the signup form.
-	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	id, err := app.users.Insert(r.Context(), form.Get("name"), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Email address is already in use")
 			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	app.recordAudit(r, auditSignup, fmt.Sprintf("user:%d", id))
+	app.metrics.signups.Inc()
+
 	// Add a confirmation flash message and redirect to the login page.
 	app.session.Put(r, "flash", "Your signup was successful. Please log in.")
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -204,19 +485,32 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 
 	// Check if credentials are valid. Redisplay the login page if there is any error.
 	form := forms.New(r.PostForm)
-	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
+	id, err := app.users.Authenticate(r.Context(), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.recordAudit(r, auditLoginFailure, "user:"+form.Get("email"))
+			app.metrics.logins.WithLabelValues("failure").Inc()
 			form.Errors.Add("generic", "Email or Password is incorrect")
 			app.render(w, r, "login.page.tmpl", &templateData{Form: form})
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// Remember whether the user logged in with a temporary password given by an admin,
+	// since the sessions from before the reset must not go on.
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.session.Put(r, "temporaryPassword", user.MustResetPassword)
+
 	// Add the user id to the session, so that this user is logged in.
 	app.session.Put(r, "authenticatedUserID", id)
+	app.recordAudit(r, auditLoginSuccess, fmt.Sprintf("user:%d", id))
+	app.metrics.logins.WithLabelValues("success").Inc()
 
 	// Redirect to the origin path that this client want to before login, if exist.
 	redirectLoc := app.session.PopString(r, "redirectLocation")
@@ -231,6 +525,9 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 
 // logoutUser let user logout.
 func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
+	// Record the logout while the user is still in the session.
+	app.recordAudit(r, auditLogout, fmt.Sprintf("user:%d", app.session.GetInt(r, "authenticatedUserID")))
+
 	// Remove the authenticatedUserID of user
 	app.session.Remove(r, "authenticatedUserID")
 	// Inform user that they are succesfully logged out
@@ -245,9 +542,9 @@ func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
 	id := app.session.GetInt(r, "authenticatedUserID")
 
 	// Retreive the data from db.
-	user, err := app.users.Get(id)
+	user, err := app.users.Get(r.Context(), id)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -257,6 +554,54 @@ func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// publicProfile shows the public profile of given user with a page of the user's public snippets.
+func (app *application) publicProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Deactivated users have no public profile.
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if !user.Active {
+		app.notFound(w)
+		return
+	}
+
+	p, ok := newPagination(r, profilePageSize)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+	s, total, err := app.snippets.ByUser(r.Context(), user.ID, listSort(r), p.Size, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+	if !p.InRange() {
+		app.notFound(w)
+		return
+	}
+
+	app.render(w, r, "user.page.tmpl", &templateData{
+		Feed:       fmt.Sprintf("/u/%d/feed", user.ID),
+		Pagination: p,
+		Snippets:   s,
+		Sort:       listSort(r),
+		User:       user,
+	})
+}
+
 // changePasswordForm show the form for users to change their passwords.
 func (app *application) changePasswordForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "password.page.tmpl", &templateData{
@@ -291,17 +636,20 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 
 	// Update the password of this user.
 	id := app.session.GetInt(r, "authenticatedUserID")
-	err = app.users.ChangePassword(id, form.Get("currentPassword"), form.Get("newPassword"))
+	err = app.users.ChangePassword(r.Context(), id, form.Get("currentPassword"), form.Get("newPassword"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.Errors.Add("currentPassword", "Wrong password")
 			app.render(w, r, "password.page.tmpl", &templateData{Form: form})
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	app.recordAudit(r, auditPasswordChange, fmt.Sprintf("user:%d", id))
+	app.session.Remove(r, "temporaryPassword")
+
 	// Add a confirmation flash message and redirect to the login page.
 	app.session.Put(r, "flash", "Change password successfully!")
 	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
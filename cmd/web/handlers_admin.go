@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// adminPageSize is the number of rows shown on each page of the admin listings.
+const adminPageSize = 20
+
+// adminStatsDays is the number of days covered by the site-wide statistics.
+const adminStatsDays = 30
+
+// dailyStats holds the site-wide statistics of a single day.
+type dailyStats struct {
+	Day         time.Time
+	Snippets    int
+	Signups     int
+	Expirations int
+}
+
+// adminDashboard shows the site-wide statistics of the last adminStatsDays days.
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	created, err := app.snippets.CreatedPerDay(r.Context(), adminStatsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	expired, err := app.snippets.ExpiredPerDay(r.Context(), adminStatsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	signups, err := app.users.SignupsPerDay(r.Context(), adminStatsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Lay the counts out on one row per day, the latest day first.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats := make([]*dailyStats, adminStatsDays)
+	for i := range stats {
+		stats[i] = &dailyStats{Day: today.AddDate(0, 0, -i)}
+	}
+	row := func(day time.Time) *dailyStats {
+		i := int(today.Sub(day.UTC().Truncate(24*time.Hour)) / (24 * time.Hour))
+		if i < 0 || i >= len(stats) {
+			return nil
+		}
+		return stats[i]
+	}
+	for _, c := range created {
+		if s := row(c.Day); s != nil {
+			s.Snippets = c.Count
+		}
+	}
+	for _, c := range expired {
+		if s := row(c.Day); s != nil {
+			s.Expirations = c.Count
+		}
+	}
+	for _, c := range signups {
+		if s := row(c.Day); s != nil {
+			s.Signups = c.Count
+		}
+	}
+
+	app.render(w, r, "admin.page.tmpl", &templateData{
+		Stats: stats,
+	})
+}
+
+// adminUsers shows a page of users matching the search query "q".
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	p, ok := newPagination(r, adminPageSize)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	users, total, err := app.users.Search(r.Context(), query, p.Size, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+	if !p.InRange() {
+		app.notFound(w)
+		return
+	}
+
+	app.render(w, r, "admin-users.page.tmpl", &templateData{
+		Pagination: p,
+		Query:      query,
+		Users:      users,
+	})
+}
+
+// adminActivateUser lets the user log in again.
+func (app *application) adminActivateUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserActive(w, r, true)
+}
+
+// adminDeactivateUser logs the user out and stops the user from logging in.
+func (app *application) adminDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserActive(w, r, false)
+}
+
+// adminSetUserActive toggles users.active of the user given by the ":id" parameter.
+func (app *application) adminSetUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Prevent admins from locking themselves out.
+	if !active && user.ID == app.session.GetInt(r, "authenticatedUserID") {
+		app.session.Put(r, "flash", "You cannot deactivate yourself.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err := app.users.SetActive(r.Context(), user.ID, active)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if active {
+		app.recordAudit(r, auditAdminUserActivate, fmt.Sprintf("user:%d", user.ID))
+		app.session.Put(r, "flash", "User "+user.Email+" has been activated.")
+	} else {
+		app.recordAudit(r, auditAdminUserDeactivate, fmt.Sprintf("user:%d", user.ID))
+		app.session.Put(r, "flash", "User "+user.Email+" has been deactivated.")
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminResetPassword replaces the password of the user by a temporary one, which
+// ends the sessions of the user and must be changed on the next login.
+func (app *application) adminResetPassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	password, err := app.users.RequirePasswordReset(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.recordAudit(r, auditAdminUserResetPassword, fmt.Sprintf("user:%d", user.ID))
+	app.session.Put(r, "flash", "User "+user.Email+" must log in with the temporary password "+password+
+		" and change it.")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminTargetUser return the user given by the ":id" parameter. If it fails,
+// then it writes the error response and return false.
+func (app *application) adminTargetUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// adminSnippets shows a page of all the snippets, including hidden and expired ones.
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	p, ok := newPagination(r, adminPageSize)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	snippets, total, err := app.snippets.List(r.Context(), p.Size, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+	if !p.InRange() {
+		app.notFound(w)
+		return
+	}
+
+	app.render(w, r, "admin-snippets.page.tmpl", &templateData{
+		Pagination: p,
+		Snippets:   snippets,
+	})
+}
+
+// adminHideSnippet hides the snippet from everyone but the admins.
+func (app *application) adminHideSnippet(w http.ResponseWriter, r *http.Request) {
+	app.adminSetSnippetHidden(w, r, true)
+}
+
+// adminUnhideSnippet makes the hidden snippet visible again.
+func (app *application) adminUnhideSnippet(w http.ResponseWriter, r *http.Request) {
+	app.adminSetSnippetHidden(w, r, false)
+}
+
+// adminSetSnippetHidden sets whether the snippet given by the ":id" parameter is hidden.
+func (app *application) adminSetSnippetHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.snippets.SetHidden(r.Context(), id, hidden)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.notifySnippet(r.Context(), webhookSnippetUpdated, id)
+
+	if hidden {
+		app.recordAudit(r, auditAdminSnippetHide, fmt.Sprintf("snippet:%d", id))
+		app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(id)+" has been hidden.")
+	} else {
+		app.recordAudit(r, auditAdminSnippetUnhide, fmt.Sprintf("snippet:%d", id))
+		app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(id)+" is visible again.")
+	}
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// adminDeleteSnippet deletes the snippet permanently.
+func (app *application) adminDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Keep the deleted snippet to tell its owner about it.
+	s, err := app.snippets.Find(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.notifyWebhooks(webhookSnippetDeleted, s)
+
+	app.recordAudit(r, auditAdminSnippetDelete, fmt.Sprintf("snippet:%d", id))
+	app.session.Put(r, "flash", "Snippet #"+strconv.Itoa(id)+" has been deleted.")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// auditFilterFromForm builds the audit log filter from the query string form. Invalid
+// fields are reported into form.Errors and left unfiltered.
+func auditFilterFromForm(form *forms.Form) models.AuditFilter {
+	form.PermittedValues("action", auditActions...)
+
+	filter := models.AuditFilter{}
+	if form.Errors.Get("action") == "" {
+		filter.Action = form.Get("action")
+	}
+	if v := form.Get("actor"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			form.Errors.Add("actor", "This field is invalid")
+		} else {
+			filter.ActorID = id
+		}
+	}
+	if v := form.Get("since"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("since", "This field is invalid")
+		} else {
+			filter.Since = t
+		}
+	}
+	// Until is inclusive, so filter the events before the following day.
+	if v := form.Get("until"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("until", "This field is invalid")
+		} else {
+			filter.Until = t.AddDate(0, 0, 1)
+		}
+	}
+	return filter
+}
+
+// adminAudit shows a page of the audit log matching the filter in the query string.
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	filter := auditFilterFromForm(form)
+	p, ok := newPagination(r, adminPageSize)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	events, total, err := app.auditEvents.List(filter, p.Size, p.Offset())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+	if !p.InRange() {
+		app.notFound(w)
+		return
+	}
+
+	app.render(w, r, "admin-audit.page.tmpl", &templateData{
+		AuditActions: auditActions,
+		AuditEvents:  events,
+		Form:         form,
+		Pagination:   p,
+	})
+}
+
+// adminAuditExport downloads all the audit events matching the filter in the query
+// string, as CSV or JSON given by the "format" parameter.
+func (app *application) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.Required("format")
+	form.PermittedValues("format", "csv", "json")
+	filter := auditFilterFromForm(form)
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, _, err := app.auditEvents.List(filter, 0, 0)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.recordAudit(r, auditAdminAuditExport, "audit:"+r.URL.RawQuery)
+
+	// Write the export to the buffer first, so that an error can still be responded.
+	buf := new(bytes.Buffer)
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), form.Get("format"))
+	switch form.Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = writeAuditCSV(buf, events)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = writeAuditJSON(buf, events)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	buf.WriteTo(w)
+}
+
+// writeAuditCSV writes the events as CSV with a header row.
+func writeAuditCSV(w io.Writer, events []*models.AuditEvent) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"id", "created", "actor_id", "action", "target", "ip", "user_agent"})
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		row := []string{
+			strconv.Itoa(e.ID),
+			e.Created.UTC().Format(time.RFC3339),
+			strconv.Itoa(e.ActorID),
+			e.Action,
+			e.Target,
+			e.IP,
+			e.UserAgent,
+		}
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell return the value escaped so that spreadsheets don't evaluate it as a
+// formula, since the targets and user agents come from the clients.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// auditEventJSON is the JSON representation of an audit event in the exports.
+type auditEventJSON struct {
+	ID        int       `json:"id"`
+	Created   time.Time `json:"created"`
+	ActorID   int       `json:"actor_id,omitempty"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// writeAuditJSON writes the events as a JSON array.
+func writeAuditJSON(w io.Writer, events []*models.AuditEvent) error {
+	out := make([]auditEventJSON, len(events))
+	for i, e := range events {
+		out[i] = auditEventJSON{e.ID, e.Created.UTC(), e.ActorID, e.Action, e.Target, e.IP, e.UserAgent}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
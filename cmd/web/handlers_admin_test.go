@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+func TestAdminAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Unauthenticated", "", http.StatusSeeOther, nil},
+		{"Non-admin", "alice@example.com", http.StatusForbidden, nil},
+		{"Admin", "carol@example.com", http.StatusOK, []byte("Site Statistics")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Use a fresh server for each case so that the sessions don't leak.
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if test.email != "" {
+				ts.login(t, test.email)
+			}
+
+			code, _, body := ts.get(t, "/admin")
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+		dontWant []byte
+	}{
+		{"All users", "/admin/users", []byte("alice@example.com"), nil},
+		{"Search", "/admin/users?q=carol", []byte("carol@example.com"), []byte("alice@example.com")},
+		{"No match", "/admin/users?q=nobody", []byte("No user matches your search."), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+			if test.dontWant != nil && bytes.Contains(body, test.dontWant) {
+				t.Errorf("want body %s not to contain %q", body, test.dontWant)
+			}
+		})
+	}
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	app := newTestApplication(t)
+	users := &resetUsers{}
+	app.users = users
+
+	// Alice and the admin use their own clients, hence their own sessions.
+	alice := newTestServer(t, app.routes())
+	defer alice.Close()
+	alice.login(t, "alice@example.com")
+
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	admin.login(t, "carol@example.com")
+
+	_, _, body := admin.get(t, "/admin/users")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := admin.postForm(t, "/admin/users/1/reset-password", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// The admin is told the temporary password.
+	_, _, body = admin.get(t, "/admin/users")
+	if !bytes.Contains(body, []byte("temporaryPa$$word")) {
+		t.Errorf("want body %s to contain the temporary password", body)
+	}
+
+	tests := []struct {
+		name    string
+		login   bool
+		wantLoc string
+	}{
+		{"Session from before the reset", false, "/user/login"},
+		{"Session with the temporary password", true, "/user/change-password"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.login {
+				alice.login(t, "alice@example.com")
+			}
+
+			code, headers, _ := alice.get(t, "/snippet/create")
+			if code != http.StatusSeeOther {
+				t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+			}
+			if loc := headers.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want %q; got %q", test.wantLoc, loc)
+			}
+		})
+	}
+}
+
+func TestAdminHideSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	audit := &auditRecorder{}
+	app.auditEvents = audit
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	_, _, body := ts.get(t, "/admin/snippets")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantAudit int
+	}{
+		{"Valid ID", "/admin/snippets/1/hide", http.StatusSeeOther, 1},
+		{"Non-existent ID", "/admin/snippets/2/hide", http.StatusNotFound, 1},
+		{"String ID", "/admin/snippets/foo/hide", http.StatusNotFound, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if n := len(audit.withAction(auditAdminSnippetHide)); n != test.wantAudit {
+				t.Errorf("want %d audit events; got %d", test.wantAudit, n)
+			}
+		})
+	}
+}
+
+func TestAdminDeleteSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	_, _, body := ts.get(t, "/admin/snippets")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Valid ID", "/admin/snippets/1/delete", http.StatusSeeOther},
+		{"Non-existent ID", "/admin/snippets/2/delete", http.StatusNotFound},
+		{"String ID", "/admin/snippets/foo/delete", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, _ := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestAdminAudit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"No filter", "/admin/audit", []byte("login.success")},
+		{"Action filter", "/admin/audit?action=login.success", []byte("192.0.2.1")},
+		{"Unmatched filter", "/admin/audit?actor=3", []byte("No event matches your filter.")},
+		{"Invalid date", "/admin/audit?since=yesterday", []byte("This field is invalid")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminAuditExport(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantType string
+		wantBody []byte
+	}{
+		{"CSV", "/admin/audit/export?format=csv", http.StatusOK, "text/csv; charset=utf-8", []byte("id,created,actor_id,action,target,ip,user_agent\n1,")},
+		{"JSON", "/admin/audit/export?format=json", http.StatusOK, "application/json", []byte(`"action":"login.success"`)},
+		{"Missing format", "/admin/audit/export", http.StatusBadRequest, "", nil},
+		{"Unknown format", "/admin/audit/export?format=xml", http.StatusBadRequest, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if test.wantType != "" && headers.Get("Content-Type") != test.wantType {
+				t.Errorf("want %q; got %q", test.wantType, headers.Get("Content-Type"))
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestWriteAuditCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		userAgent string
+		wantRow   string
+	}{
+		{"Plain", "user:1", "Mozilla/5.0", "user:1,192.0.2.1,Mozilla/5.0\n"},
+		{"Formula", "user:=1+1", "=HYPERLINK(\"http://example.com\")", "user:=1+1,192.0.2.1,\"'=HYPERLINK(\"\"http://example.com\"\")\"\n"},
+		{"Plus", "+1", "@SUM(A1)", "'+1,192.0.2.1,'@SUM(A1)\n"},
+		{"Minus", "-1", "\tcmd", "'-1,192.0.2.1,'\tcmd\n"},
+		{"Carriage return", "\rcmd", "", "\"'\rcmd\",192.0.2.1,\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := []*models.AuditEvent{{
+				ID:        1,
+				Created:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Action:    auditLoginSuccess,
+				Target:    test.target,
+				IP:        "192.0.2.1",
+				UserAgent: test.userAgent,
+			}}
+
+			buf := new(bytes.Buffer)
+			err := writeAuditCSV(buf, events)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "1,2024-01-02T03:04:05Z,0,login.success," + test.wantRow
+			if !bytes.HasSuffix(buf.Bytes(), []byte(want)) {
+				t.Errorf("want %q to end with %q", buf.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// userCollections shows the collections of the current user and the form to create one.
+func (app *application) userCollections(w http.ResponseWriter, r *http.Request) {
+	app.renderCollections(w, r, forms.New(nil))
+}
+
+// renderCollections renders the collections of the current user with the given form
+// for a new collection.
+func (app *application) renderCollections(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	collections, err := app.collections.ByUser(app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "collections.page.tmpl", &templateData{
+		Collections: collections,
+		Form:        form,
+	})
+}
+
+// createCollection creates an empty collection owned by the current user.
+func (app *application) createCollection(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "visibility")
+	form.MaxLength("name", 100)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityPrivate)
+
+	if !form.Valid() {
+		app.renderCollections(w, r, form)
+		return
+	}
+
+	id, err := app.collections.Insert(app.session.GetInt(r, "authenticatedUserID"), form.Get("name"),
+		form.Get("visibility"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection successfully created!")
+	http.Redirect(w, r, fmt.Sprintf("/collection/%d", id), http.StatusSeeOther)
+}
+
+// showCollection shows a specific collection with the snippets in it which the current
+// user can see. Private collections can only be seen by their owners.
+func (app *application) showCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.targetCollection(w, r)
+	if !ok {
+		return
+	}
+
+	isOwner := c.UserID == app.session.GetInt(r, "authenticatedUserID")
+	if c.Visibility == models.VisibilityPrivate && !isOwner {
+		app.notFound(w)
+		return
+	}
+
+	snippets, err := app.collections.Snippets(c.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// A public collection may contain snippets which became private afterwards.
+	visible := []*models.Snippet{}
+	for _, s := range snippets {
+		if app.canViewSnippet(r, s) {
+			visible = append(visible, s)
+		}
+	}
+
+	app.render(w, r, "collection.page.tmpl", &templateData{
+		Collection: c,
+		Snippets:   visible,
+	})
+}
+
+// deleteCollection deletes a specific collection of the current user.
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.collections.Delete(c.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("Collection %q has been deleted.", c.Name))
+	http.Redirect(w, r, "/collections", http.StatusSeeOther)
+}
+
+// addToCollection adds a specific snippet to the collection of the current user given
+// by "collection_id". Only the snippets the user can see can be added.
+func (app *application) addToCollection(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("collection_id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	c, err := app.collections.Get(id)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if err != nil || c.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.notFound(w)
+		return
+	}
+
+	err = app.collections.AddSnippet(c.ID, s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("Snippet added to %q.", c.Name))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", s.ID), http.StatusSeeOther)
+}
+
+// removeFromCollection removes the snippet given by "snippet_id" from a specific
+// collection of the current user.
+func (app *application) removeFromCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	snippetID, ok := app.formSnippetID(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.collections.RemoveSnippet(c.ID, snippetID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%d", c.ID), http.StatusSeeOther)
+}
+
+// moveInCollection moves the snippet given by "snippet_id" one place up or down,
+// according to "direction", in a specific collection of the current user.
+func (app *application) moveInCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	snippetID, ok := app.formSnippetID(w, r)
+	if !ok {
+		return
+	}
+
+	direction := r.PostForm.Get("direction")
+	if direction != "up" && direction != "down" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.collections.MoveSnippet(c.ID, snippetID, direction == "up")
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%d", c.ID), http.StatusSeeOther)
+}
+
+// targetCollection return the collection given by the ":id" parameter. If it fails,
+// then it writes the error response and return false.
+func (app *application) targetCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	c, err := app.collections.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return c, true
+}
+
+// ownedCollection return the collection given by the ":id" parameter if it is owned by
+// the current user. Otherwise it writes a 404, pretending that the collection of other
+// users does not exist, and return false.
+func (app *application) ownedCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	c, ok := app.targetCollection(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if c.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.notFound(w)
+		return nil, false
+	}
+	return c, true
+}
+
+// formSnippetID return the id posted as "snippet_id". If it is invalid, then it writes
+// a 400 response and return false.
+func (app *application) formSnippetID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("snippet_id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestShowCollection(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		wantCode     int
+		wantBody     []byte
+		dontWantBody []byte
+	}{
+		{"Public collection", "", "/collection/1", http.StatusOK, []byte("<a href='/snippet/1'>An old silent pond</a>"), []byte("A secret note")},
+		{"Public collection of owner", "alice@example.com", "/collection/1", http.StatusOK, []byte("<a href='/snippet/3'>A secret note</a>"), nil},
+		{"Private collection", "carol@example.com", "/collection/2", http.StatusNotFound, nil, nil},
+		{"Private collection of owner", "alice@example.com", "/collection/2", http.StatusOK, []byte("Drafts"), nil},
+		{"Non-existent ID", "", "/collection/99", http.StatusNotFound, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			if test.email != "" {
+				ts.login(t, test.email)
+			}
+
+			code, _, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+			if test.dontWantBody != nil && bytes.Contains(body, test.dontWantBody) {
+				t.Errorf("want body %s not to contain %q", body, test.dontWantBody)
+			}
+		})
+	}
+}
+
+func TestCreateCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	code, _, body := ts.get(t, "/collections")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	want := []byte("<a href='/collection/1'>Onboarding</a>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		collName   string
+		visibility string
+		wantCode   int
+		wantLoc    string
+	}{
+		{"Valid", "Go tips", "public", http.StatusSeeOther, "/collection/3"},
+		{"Empty name", "", "public", http.StatusOK, ""},
+		{"Invalid visibility", "Go tips", "unlisted", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", test.collName)
+			form.Add("visibility", test.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/collections", form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want %q; got %q", test.wantLoc, loc)
+			}
+		})
+	}
+}
+
+func TestEditCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	// The show page offers to add the snippet to the collections of the user.
+	_, _, body := ts.get(t, "/snippet/4")
+	want := []byte("<option value='1'>Onboarding</option>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		form     url.Values
+		wantCode int
+		wantLoc  string
+	}{
+		{"Add", "/snippet/4/collect", url.Values{"collection_id": {"1"}}, http.StatusSeeOther, "/snippet/4"},
+		{"Add invisible snippet", "/snippet/2/collect", url.Values{"collection_id": {"1"}}, http.StatusNotFound, ""},
+		{"Add to non-existent collection", "/snippet/4/collect", url.Values{"collection_id": {"99"}}, http.StatusNotFound, ""},
+		{"Move up", "/collection/1/move", url.Values{"snippet_id": {"3"}, "direction": {"up"}}, http.StatusSeeOther, "/collection/1"},
+		{"Move snippet not in collection", "/collection/1/move", url.Values{"snippet_id": {"4"}, "direction": {"up"}}, http.StatusNotFound, ""},
+		{"Move sideways", "/collection/1/move", url.Values{"snippet_id": {"3"}, "direction": {"left"}}, http.StatusBadRequest, ""},
+		{"Remove", "/collection/1/remove", url.Values{"snippet_id": {"1"}}, http.StatusSeeOther, "/collection/1"},
+		{"Delete", "/collection/1/delete", url.Values{}, http.StatusSeeOther, "/collections"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, test.urlPath, test.form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want %q; got %q", test.wantLoc, loc)
+			}
+		})
+	}
+}
+
+func TestEditCollectionOfOtherUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	_, _, body := ts.get(t, "/snippet/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	for _, urlPath := range []string{"/collection/1/delete", "/collection/1/remove"} {
+		form := url.Values{}
+		form.Add("snippet_id", "1")
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, urlPath, form)
+		if code != http.StatusNotFound {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusNotFound, code)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// commentEditWindow is how long the author can edit or delete a comment after posting it.
+const commentEditWindow = 15 * time.Minute
+
+// commentMaxLength is the maximum number of characters in a comment.
+const commentMaxLength = 2000
+
+// canEditComment return true if the user with given id can still edit the comment.
+func canEditComment(c *models.Comment, userID int) bool {
+	return userID != 0 && c.UserID == userID && time.Since(c.Created) < commentEditWindow
+}
+
+// canDeleteComment return true if the user with given id can delete the comment on
+// the snippet s. Besides the author within the edit window, the owner of the snippet
+// and the admins can delete any comment on it.
+func canDeleteComment(c *models.Comment, s *models.Snippet, userID int, isAdmin bool) bool {
+	if userID == 0 {
+		return false
+	}
+	return canEditComment(c, userID) || isAdmin || (s.UserID != 0 && s.UserID == userID)
+}
+
+// createComment posts a comment, or a reply if "parent_id" is given, on a specific snippet.
+func (app *application) createComment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("body")
+	form.MaxLength("body", commentMaxLength)
+
+	// Replies are only one level deep, so a reply to a reply goes to the top-level comment.
+	parentID := 0
+	if v := form.Get("parent_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		parent, err := app.comments.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		if err != nil || parent.SnippetID != s.ID {
+			form.Errors.Add("body", "The comment you are replying to no longer exists")
+		} else if parent.ParentID != 0 {
+			parentID = parent.ParentID
+		} else {
+			parentID = parent.ID
+		}
+	}
+
+	// Redisplay the snippet with the filled-in comment if the form is not valid.
+	if !form.Valid() {
+		app.renderSnippet(w, r, s, form)
+		return
+	}
+
+	_, err = app.comments.Insert(s.ID, app.session.GetInt(r, "authenticatedUserID"), parentID, form.Get("body"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your comment has been posted.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d#comments", s.ID), http.StatusSeeOther)
+}
+
+// editCommentForm shows the form for the author to edit a specific comment.
+func (app *application) editCommentForm(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.editableComment(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(url.Values{"body": {c.Body}})
+
+	app.render(w, r, "comment.page.tmpl", &templateData{
+		Comment: c,
+		Form:    form,
+	})
+}
+
+// editComment updates a specific comment with the submitted body.
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.editableComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("body")
+	form.MaxLength("body", commentMaxLength)
+
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &templateData{Comment: c, Form: form})
+		return
+	}
+
+	err = app.comments.Update(c.ID, form.Get("body"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Your comment has been updated.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d#comment-%d", c.SnippetID, c.ID), http.StatusSeeOther)
+}
+
+// deleteComment deletes a specific comment with its replies.
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.targetComment(w, r)
+	if !ok {
+		return
+	}
+
+	// The snippet must still be visible to the user to moderate its comments.
+	s, ok := app.commentSnippet(w, r, c)
+	if !ok {
+		return
+	}
+
+	if !canDeleteComment(c, s, app.session.GetInt(r, "authenticatedUserID"), app.isAdmin(r)) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.comments.Delete(c.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "The comment has been deleted.")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%d#comments", s.ID), http.StatusSeeOther)
+}
+
+// editableComment return the comment given by the ":id" parameter if the current user
+// can still edit it. Otherwise it writes the error response and return false.
+func (app *application) editableComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	c, ok := app.targetComment(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// The snippet must still be visible to the user to edit its comments.
+	if _, ok := app.commentSnippet(w, r, c); !ok {
+		return nil, false
+	}
+
+	if !canEditComment(c, app.session.GetInt(r, "authenticatedUserID")) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return c, true
+}
+
+// commentSnippet return the snippet of the comment c if the current user can still view
+// it, which is not the case once it has expired or been hidden. Otherwise it writes the
+// error response and return false.
+func (app *application) commentSnippet(w http.ResponseWriter, r *http.Request, c *models.Comment) (*models.Snippet, bool) {
+	s, err := app.snippets.Get(r.Context(), c.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	if !app.canViewSnippet(r, s) {
+		app.notFound(w)
+		return nil, false
+	}
+	return s, true
+}
+
+// targetComment return the comment given by the ":id" parameter. If it fails, then
+// it writes the error response and return false.
+func (app *application) targetComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	c, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return c, true
+}
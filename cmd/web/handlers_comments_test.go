@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestShowComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/1")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{
+		"A <strong>classic</strong> by Basho.",
+		"Thanks for <em>sharing</em>!",
+		"<a href='/user/login'>Login</a> to leave a comment.",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+
+	// Anonymous users cannot edit comments.
+	code, headers, _ := ts.get(t, "/comment/1/edit")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := headers.Get("Location"); loc != "/user/login" {
+		t.Errorf("want %q; got %q", "/user/login", loc)
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	_, _, body := ts.get(t, "/snippet/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		body     string
+		parentID string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid comment", "/snippet/1/comments", "Nice!", "", http.StatusSeeOther, nil},
+		{"Valid reply", "/snippet/1/comments", "Nice!", "2", http.StatusSeeOther, nil},
+		{"Empty body", "/snippet/1/comments", "", "", http.StatusOK, []byte("This field cannot be blank")},
+		{"Non-existent parent", "/snippet/1/comments", "Nice!", "99", http.StatusOK, []byte("The comment you are replying to no longer exists")},
+		{"Invalid parent", "/snippet/1/comments", "Nice!", "abc", http.StatusBadRequest, nil},
+		{"Private snippet of other user", "/snippet/3/comments", "Nice!", "", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", test.body)
+			form.Add("parent_id", test.parentID)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	code, _, body := ts.get(t, "/comment/1/edit")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	want := []byte("<textarea name='body'>A **classic** by Basho.</textarea>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantLoc  string
+	}{
+		{"Own recent comment", "/comment/1/edit", http.StatusSeeOther, "/snippet/1#comment-1"},
+		{"Comment of other user", "/comment/2/edit", http.StatusForbidden, ""},
+		{"Own comment after the window", "/comment/3/edit", http.StatusForbidden, ""},
+		{"Own comment on an expired snippet", "/comment/6/edit", http.StatusNotFound, ""},
+		{"Non-existent ID", "/comment/99/edit", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", "Edited")
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want %q; got %q", test.wantLoc, loc)
+			}
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Author within the window", "alice@example.com", "/comment/1/delete", http.StatusSeeOther},
+		{"Snippet owner", "alice@example.com", "/comment/2/delete", http.StatusSeeOther},
+		{"Admin", "carol@example.com", "/comment/3/delete", http.StatusSeeOther},
+		{"Non-existent ID", "alice@example.com", "/comment/99/delete", http.StatusNotFound},
+		{"Comment on an expired snippet", "alice@example.com", "/comment/6/delete", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			ts.login(t, test.email)
+
+			_, _, body := ts.get(t, "/snippet/1")
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+const (
+	// embedWidth and embedHeight are the default size of an embedded snippet in pixels.
+	embedWidth  = 600
+	embedHeight = 400
+)
+
+// snippetPathRX matches the path of the page of a snippet and captures its id.
+var snippetPathRX = regexp.MustCompile(`^/snippet/([1-9][0-9]*)$`)
+
+// oEmbed is an oEmbed response of the "rich" type, see https://oembed.com.
+type oEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	AuthorURL    string `json:"author_url,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// embedSnippet shows a specific snippet in a minimal page meant to be framed by other
+// sites. Private snippets are never embedded, not even for their owners. The page is
+// served without session, so that framing it does not consume the flash of the user.
+func (app *application) embedSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.embeddableSnippet(w, r, r.URL.Query().Get(":id"))
+	if !ok {
+		return
+	}
+
+	app.viewCounter.Record(s.ID, app.viewer(r), time.Now())
+
+	var content template.HTML
+	if s.ContentType == models.ContentTypeMarkdown {
+		var err error
+		content, err = app.markdown.Render(s.ID, s.Revision, s.Content)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.renderPage(w, r, "embed.page.tmpl", &templateData{
+		RenderedContent: content,
+		Snippet:         s,
+	})
+}
+
+// oEmbed return the oEmbed JSON of the snippet given by the "url" query parameter, which
+// must be the URL of a snippet page on this site. Only the JSON format is supported.
+func (app *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	u, err := url.Parse(query.Get("url"))
+	if err != nil || u.Host != app.baseURL.Host {
+		app.notFound(w)
+		return
+	}
+	matches := snippetPathRX.FindStringSubmatch(u.Path)
+	if matches == nil {
+		app.notFound(w)
+		return
+	}
+
+	s, ok := app.embeddableSnippet(w, r, matches[1])
+	if !ok {
+		return
+	}
+
+	width, height := embedWidth, embedHeight
+	if n, err := strconv.Atoi(query.Get("maxwidth")); err == nil && n > 0 && n < width {
+		width = n
+	}
+	if n, err := strconv.Atoi(query.Get("maxheight")); err == nil && n > 0 && n < height {
+		height = n
+	}
+
+	embed := &oEmbed{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        s.Title,
+		ProviderName: "Snippetbox",
+		ProviderURL:  app.absoluteURL("/"),
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" title="%s"></iframe>`,
+			template.HTMLEscapeString(app.absoluteURL(fmt.Sprintf("/embed/%d", s.ID))), width, height,
+			template.HTMLEscapeString(s.Title)),
+		Width:  width,
+		Height: height,
+	}
+	if s.UserID != 0 {
+		embed.AuthorName = s.UserName
+		embed.AuthorURL = app.absoluteURL(fmt.Sprintf("/u/%d", s.UserID))
+	}
+
+	// Let the wikis which fetch the oEmbed from the browser read it.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	app.writeJSON(w, r, http.StatusOK, embed)
+}
+
+// embeddableSnippet return the snippet with the given id if it can be embedded, that is
+// if it is not private. Otherwise it writes a 404 or 500 response and return false.
+func (app *application) embeddableSnippet(w http.ResponseWriter, r *http.Request, id string) (*models.Snippet, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	s, err := app.snippets.Get(r.Context(), n)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if s.Visibility == models.VisibilityPrivate {
+		app.notFound(w)
+		return nil, false
+	}
+	return s, true
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEmbedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Public snippet", "/embed/1", http.StatusOK, []byte("<pre><code>An old silent pond...</code></pre>")},
+		{"Several files", "/embed/4", http.StatusOK, []byte("<a href='/snippet/4/raw/splash.txt'>splash.txt</a>")},
+		{"Markdown snippet", "/embed/5", http.StatusOK, []byte("<div class='markdown'>")},
+		{"Private snippet of owner", "/embed/3", http.StatusNotFound, nil},
+		{"Non-existent ID", "/embed/2", http.StatusNotFound, nil},
+		{"String ID", "/embed/foo", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, header, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if got := header.Get("X-Frame-Options"); got != "" {
+				t.Errorf("want no X-Frame-Options; got %q", got)
+			}
+			want := "frame-ancestors https://wiki.example.com"
+			if got := header.Get("Content-Security-Policy"); got != want {
+				t.Errorf("want Content-Security-Policy %q; got %q", want, got)
+			}
+		})
+	}
+
+	// Other pages still refuse to be framed.
+	_, header, _ := ts.get(t, "/snippet/1")
+	if got := header.Get("X-Frame-Options"); got != "deny" {
+		t.Errorf("want X-Frame-Options %q; got %q", "deny", got)
+	}
+}
+
+func TestEmbedSnippetKeepsFlash(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	if code, _, _ := ts.postForm(t, "/user/logout", form); code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// A framed snippet loaded meanwhile does not consume the flash.
+	if code, header, _ := ts.get(t, "/embed/1"); code != http.StatusOK || header.Get("Set-Cookie") != "" {
+		t.Errorf("want %d without cookie; got %d with %q", http.StatusOK, code, header.Get("Set-Cookie"))
+	}
+	_, _, body = ts.get(t, "/")
+	if want := []byte("been logged out succesfully!"); !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	host := app.baseURL.Host
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantCode   int
+		wantWidth  int
+		wantAuthor string
+	}{
+		{"Public snippet", url.Values{"url": {"https://" + host + "/snippet/1"}}, http.StatusOK, 600, "Alice"},
+		{"JSON format", url.Values{"url": {"https://" + host + "/snippet/1"}, "format": {"json"}}, http.StatusOK, 600, "Alice"},
+		{"Max width", url.Values{"url": {"https://" + host + "/snippet/1"}, "maxwidth": {"320"}}, http.StatusOK, 320, "Alice"},
+		{"Unlisted snippet", url.Values{"url": {"https://" + host + "/snippet/5"}}, http.StatusOK, 600, "Carol"},
+		{"XML format", url.Values{"url": {"https://" + host + "/snippet/1"}, "format": {"xml"}}, http.StatusNotImplemented, 0, ""},
+		{"Private snippet", url.Values{"url": {"https://" + host + "/snippet/3"}}, http.StatusNotFound, 0, ""},
+		{"Other site", url.Values{"url": {"https://example.com/snippet/1"}}, http.StatusNotFound, 0, ""},
+		{"Other page", url.Values{"url": {"https://" + host + "/snippet/1/fork"}}, http.StatusNotFound, 0, ""},
+		{"Missing URL", url.Values{}, http.StatusNotFound, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, header, body := ts.get(t, "/oembed?"+test.query.Encode())
+			if code != test.wantCode {
+				t.Fatalf("want %d; got %d", test.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if got := header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("want Content-Type %q; got %q", "application/json", got)
+			}
+
+			var embed oEmbed
+			if err := json.Unmarshal(body, &embed); err != nil {
+				t.Fatal(err)
+			}
+			if embed.Version != "1.0" || embed.Type != "rich" {
+				t.Errorf("want a rich oEmbed 1.0; got %+v", embed)
+			}
+			if embed.Width != test.wantWidth {
+				t.Errorf("want width %d; got %d", test.wantWidth, embed.Width)
+			}
+			if embed.AuthorName != test.wantAuthor {
+				t.Errorf("want author %q; got %q", test.wantAuthor, embed.AuthorName)
+			}
+			if !strings.Contains(embed.HTML, `<iframe src="https://`+host+`/embed/`) {
+				t.Errorf("want an iframe of the embed page; got %q", embed.HTML)
+			}
+		})
+	}
+}
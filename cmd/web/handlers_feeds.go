@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"kerseeeHuang.com/snippetbox/pkg/feed"
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// feedSize is the number of snippets in a feed.
+const feedSize = 10
+
+// latestFeed serves the latest public snippets as a feed.
+func (app *application) latestFeed(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Latest(r.Context(), "", models.SortLatest)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, &feed.Feed{
+		ID:          app.feedTag("feed"),
+		Title:       "Snippetbox: Latest Snippets",
+		Description: "The latest snippets on Snippetbox",
+		Link:        app.absoluteURL("/"),
+		Entries:     app.snippetEntries(s, ""),
+	}, "", 0)
+}
+
+// tagFeed serves the latest public snippets with a specific tag as a feed.
+func (app *application) tagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get(":tag")
+	if !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+
+	s, err := app.snippets.Latest(r.Context(), tag, models.SortLatest)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, &feed.Feed{
+		ID:          app.feedTag("feed/tags/" + tag),
+		Title:       fmt.Sprintf("Snippetbox: Snippets Tagged %q", tag),
+		Description: fmt.Sprintf("The latest snippets tagged %q on Snippetbox", tag),
+		Link:        app.absoluteURL("/tags/" + tag),
+		Entries:     app.snippetEntries(s, ""),
+	}, tag, 0)
+}
+
+// userFeed serves the latest public snippets of a specific user as a feed.
+func (app *application) userFeed(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Deactivated users have no public feed.
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if !user.Active {
+		app.notFound(w)
+		return
+	}
+
+	s, _, err := app.snippets.ByUser(r.Context(), user.ID, models.SortLatest, feedSize, 0)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.serveFeed(w, r, &feed.Feed{
+		ID:          app.feedTag(fmt.Sprintf("feed/u/%d", user.ID)),
+		Title:       fmt.Sprintf("Snippetbox: Snippets by %s", user.Name),
+		Description: fmt.Sprintf("The latest snippets by %s on Snippetbox", user.Name),
+		Link:        app.absoluteURL(fmt.Sprintf("/u/%d", user.ID)),
+		Author:      user.Name,
+		Entries:     app.snippetEntries(s, user.Name),
+	}, "", user.ID)
+}
+
+// serveFeed writes f of the snippets with the tag, or of the user if userID is not 0, in
+// the format given by the extension of the request path, either ".atom" or ".rss". The
+// response has an ETag of its content and a Last-Modified date, so that the conditional
+// requests of polling clients are answered with 304 Not Modified. The date includes the
+// last time a snippet of the feed expired or was hidden, which no date of the entries tells.
+// A snippet deleted by an admin leaves no date, so only the ETag tells that it left.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, f *feed.Feed, tag string, userID int) {
+	f.Self = app.absoluteURL(r.URL.Path)
+
+	modified, err := app.snippets.FeedModified(r.Context(), tag, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	f.Modified = modified
+
+	// Fix the date of the feed, since it is the current time if there has never been a
+	// snippet in the feed, and the body and the header must tell the same date.
+	f.Modified = f.Updated()
+
+	var b []byte
+	switch path.Ext(r.URL.Path) {
+	case ".atom":
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		b, err = f.Atom()
+	case ".rss":
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		b, err = f.RSS()
+	default:
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(b)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+
+	// ServeContent handles If-None-Match, and If-Modified-Since when there is none.
+	http.ServeContent(w, r, "", f.Modified, bytes.NewReader(b))
+}
+
+// snippetEntries return the snippets as feed entries. Snippets never change after they
+// are created, so an entry is updated when it is published.
+func (app *application) snippetEntries(snippets []*models.Snippet, author string) []*feed.Entry {
+	entries := []*feed.Entry{}
+	for _, s := range snippets {
+		entries = append(entries, &feed.Entry{
+			ID:        app.feedTag(fmt.Sprintf("snippet/%d", s.ID)),
+			Title:     s.Title,
+			Link:      app.absoluteURL(fmt.Sprintf("/snippet/%d", s.ID)),
+			Content:   s.Content,
+			Author:    author,
+			Published: s.Created,
+			Updated:   s.Created,
+		})
+	}
+	return entries
+}
+
+// feedTag return the tag URI (RFC 4151) of the given specific, which identifies a feed
+// or an entry permanently, even if the scheme or the port of the site changes.
+func (app *application) feedTag(specific string) string {
+	return fmt.Sprintf("tag:%s,2022:%s", app.baseURL.Hostname(), specific)
+}
+
+// absoluteURL return the absolute URL of the given path on the base URL of the site.
+// The Host header is not used, as it is given by the client and would end up in the
+// cached responses.
+func (app *application) absoluteURL(path string) string {
+	return strings.TrimSuffix(app.baseURL.String(), "/") + path
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantContentType string
+		wantBody        []byte
+	}{
+		{"Latest Atom", "/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<id>tag:snippetbox.example.com,2022:snippet/1</id>")},
+		{"Latest RSS", "/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8", []byte(`<guid isPermaLink="false">tag:snippetbox.example.com,2022:snippet/1</guid>`)},
+		{"Tag Atom", "/tags/haiku/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<id>tag:snippetbox.example.com,2022:feed/tags/haiku</id>")},
+		{"Empty tag RSS", "/tags/go/feed.rss", http.StatusOK, "application/rss+xml; charset=utf-8", []byte("<title>Snippetbox: Snippets Tagged &#34;go&#34;</title>")},
+		{"Invalid tag", "/tags/Not%20a%20tag/feed.atom", http.StatusNotFound, "", nil},
+		{"User Atom", "/u/1/feed.atom", http.StatusOK, "application/atom+xml; charset=utf-8", []byte("<name>Alice</name>")},
+		{"Non-existent user", "/u/99/feed.atom", http.StatusNotFound, "", nil},
+		{"Invalid user ID", "/u/foo/feed.rss", http.StatusNotFound, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, header, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if test.wantContentType != "" && header.Get("Content-Type") != test.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", test.wantContentType, header.Get("Content-Type"))
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, header, _ := ts.get(t, "/feed.atom")
+	etag := header.Get("ETag")
+	if etag == "" {
+		t.Fatal("want an ETag header")
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		t.Fatalf("want a Last-Modified header: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"Matching ETag", "If-None-Match", etag, http.StatusNotModified},
+		{"Stale ETag", "If-None-Match", `"stale"`, http.StatusOK},
+		{"Not modified since", "If-Modified-Since", lastModified.Format(http.TimeFormat), http.StatusNotModified},
+		{"Modified since", "If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, _ := ts.getWithHeader(t, "/feed.atom", http.Header{test.header: {test.value}})
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestEmptyFeedUpdated(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A feed which never had a snippet is dated now, instead of in year 1.
+	before := time.Now().Truncate(time.Second)
+	_, header, body := ts.get(t, "/tags/go/feed.atom")
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		t.Fatalf("want a Last-Modified header: %v", err)
+	}
+	if lastModified.Before(before) {
+		t.Errorf("want Last-Modified to be the current time; got %v", lastModified)
+	}
+	if bytes.Contains(body, []byte("0001-01-01")) {
+		t.Errorf("want body %s not to contain the zero time", body)
+	}
+}
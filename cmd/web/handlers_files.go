@@ -0,0 +1,122 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// maxFilesPerSnippet is the maximum number of files in a snippet.
+const maxFilesPerSnippet = 10
+
+// renderSnippetForm renders the form for creating a snippet, with a row for each file in form.
+func (app *application) renderSnippetForm(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	app.render(w, r, "create.page.tmpl", &templateData{
+		Files: formFiles(form),
+		Form:  form,
+	})
+}
+
+// formFiles return the files given by the repeated "file_name" and "file_content"
+// values of form, with at least one empty file.
+func formFiles(form *forms.Form) []*models.SnippetFile {
+	names, contents := form.Values["file_name"], form.Values["file_content"]
+	n := len(names)
+	if len(contents) > n {
+		n = len(contents)
+	}
+
+	files := []*models.SnippetFile{}
+	for i := 0; i < n; i++ {
+		f := &models.SnippetFile{}
+		if i < len(names) {
+			f.Name = names[i]
+		}
+		if i < len(contents) {
+			f.Content = contents[i]
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		files = append(files, &models.SnippetFile{})
+	}
+	return files
+}
+
+// editFormFiles applies the "add_file" or "remove_file" button pressed in the snippet
+// form to the files in form.
+func editFormFiles(form *forms.Form) {
+	files := formFiles(form)
+	if form.Get("add_file") != "" && len(files) < maxFilesPerSnippet {
+		files = append(files, &models.SnippetFile{})
+	}
+	if i, err := strconv.Atoi(form.Get("remove_file")); err == nil && i >= 0 && i < len(files) && len(files) > 1 {
+		files = append(files[:i], files[i+1:]...)
+	}
+
+	form.Del("add_file")
+	form.Del("remove_file")
+	form.Del("file_name")
+	form.Del("file_content")
+	for _, f := range files {
+		form.Add("file_name", f.Name)
+		form.Add("file_content", f.Content)
+	}
+}
+
+// rawSnippetFile serves the content of a file in a specific snippet as plain text.
+func (app *application) rawSnippetFile(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get(":name")
+	for _, f := range s.Files {
+		if f.Name == name {
+			app.writeText(w, f.Content)
+			return
+		}
+	}
+	app.notFound(w)
+}
+
+// downloadSnippet serves all the files in a specific snippet as a ZIP archive.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Write the archive to a buffer first, so that an error can still be responded.
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, f := range s.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: s.Created,
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if _, err = fw.Write([]byte(f.Content)); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippet-%d.zip"`, s.ID))
+	buf.WriteTo(w)
+}
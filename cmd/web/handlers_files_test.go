@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestShowSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/4")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{
+		"<label for='file-0'>pond.txt</label>",
+		"<label for='file-1'>splash.txt</label>",
+		"<a href='/snippet/4/raw/splash.txt'>Raw</a>",
+		"<pre><code>Splash! Silence again.</code></pre>",
+		"<a href='/snippet/4/download' class='download'>Download ZIP</a>",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+}
+
+func TestRawSnippetFile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid file", "/snippet/4/raw/splash.txt", http.StatusOK, []byte("Splash! Silence again.")},
+		{"Non-existent file", "/snippet/4/raw/missing.txt", http.StatusNotFound, nil},
+		{"Private snippet of other user", "/snippet/3/raw/secret.txt", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if code == http.StatusOK && headers.Get("Content-Type") != "text/plain; charset=utf-8" {
+				t.Errorf("want plain text; got %q", headers.Get("Content-Type"))
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestDownloadSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/snippet/4/download")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if want := `attachment; filename="snippet-4.zip"`; headers.Get("Content-Disposition") != want {
+		t.Errorf("want %q; got %q", want, headers.Get("Content-Disposition"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"pond.txt":   "An old silent pond...\nA frog jumps into the pond",
+		"splash.txt": "Splash! Silence again.",
+	}
+	if len(zr.File) != len(want) {
+		t.Errorf("want %d files; got %d", len(want), len(zr.File))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want[f.Name] {
+			t.Errorf("want %s to be %q; got %q", f.Name, want[f.Name], content)
+		}
+	}
+
+	// Private snippets of other users cannot be downloaded.
+	code, _, _ = ts.get(t, "/snippet/3/download")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestCreateSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		files    [][2]string
+		button   [2]string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Several files", [][2]string{{"Dockerfile", "FROM golang"}, {"run.sh", "go run ."}}, [2]string{}, http.StatusSeeOther, nil},
+		{"Duplicate names", [][2]string{{"a.txt", "x"}, {"a.txt", "y"}}, [2]string{}, http.StatusOK, []byte(`There is already a file named &#34;a.txt&#34;`)},
+		{"Unnamed among several", [][2]string{{"a.txt", "x"}, {"", "y"}}, [2]string{}, http.StatusOK, []byte("This field cannot be blank")},
+		{"Add file", [][2]string{{"a.txt", "x"}}, [2]string{"add_file", "1"}, http.StatusOK, []byte("<button name='remove_file' value='1'>Remove</button>")},
+		{"Remove file", [][2]string{{"a.txt", "x"}, {"b.txt", "y"}}, [2]string{"remove_file", "0"}, http.StatusOK, []byte("<input type='text' name='file_name' value='b.txt'")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("expires", "7")
+			form.Add("visibility", "public")
+			for _, f := range test.files {
+				form.Add("file_name", f[0])
+				form.Add("file_content", f[1])
+			}
+			if test.button[0] != "" {
+				form.Add(test.button[0], test.button[1])
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
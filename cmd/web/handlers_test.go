@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -66,9 +68,70 @@ func TestShowSnippet(t *testing.T) {
 	}
 }
 
+func TestShowSnippetNegotiation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        []byte
+	}{
+		{"Browser", "text/html,*/*;q=0.8", "text/html; charset=utf-8", []byte("<title>Snippet #1 - Snippetbox</title>")},
+		{"No Accept", "", "text/html; charset=utf-8", []byte("<title>Snippet #1 - Snippetbox</title>")},
+		{"JSON", "application/json", "application/json", []byte(`"title":"An old silent pond"`)},
+		{"Plain text", "text/plain", "text/plain; charset=utf-8", []byte("An old silent pond...")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.accept != "" {
+				header.Set("Accept", test.accept)
+			}
+			code, rsHeader, body := ts.getWithHeader(t, "/snippet/1", header)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if got := rsHeader.Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("want Content-Type %q; got %q", test.wantContentType, got)
+			}
+			if !strings.Contains(strings.Join(rsHeader.Values("Vary"), ","), "Accept") {
+				t.Errorf("want Vary to contain Accept; got %q", rsHeader.Values("Vary"))
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+
+	// The JSON has the files, the author and the absolute URLs of the snippet.
+	_, _, body := ts.getWithHeader(t, "/snippet/4", http.Header{"Accept": {"application/json"}})
+	var s snippetJSON
+	if err := json.Unmarshal(body, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != 4 || len(s.Files) != 2 || s.Author == nil || s.Author.ID != 2 || s.ForkedFrom == nil || *s.ForkedFrom != 1 {
+		t.Errorf("want snippet 4 with 2 files by user 2 forked from 1; got %+v", s)
+	}
+	if want := "https://snippetbox.example.com/snippet/4/raw/splash.txt"; s.Files[1].RawURL != want {
+		t.Errorf("want raw URL %q; got %q", want, s.Files[1].RawURL)
+	}
+
+	// Private snippets are still hidden from other users.
+	code, _, _ := ts.getWithHeader(t, "/snippet/3", http.Header{"Accept": {"application/json"}})
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
 func TestSignupUser(t *testing.T) {
 	// Initialize a test app and server.
 	app := newTestApplication(t)
+	audit := &auditRecorder{}
+	app.auditEvents = audit
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
@@ -116,6 +179,12 @@ func TestSignupUser(t *testing.T) {
 			}
 		})
 	}
+
+	// The signup is audited with the id of the new user, not the email address.
+	events := audit.withAction(auditSignup)
+	if len(events) != 1 || events[0].Target != "user:3" {
+		t.Errorf("want one signup event on %q; got %+v", "user:3", events)
+	}
 }
 
 func TestCreateSnippetForm(t *testing.T) {
@@ -162,3 +231,374 @@ func TestCreateSnippetForm(t *testing.T) {
 		}
 	})
 }
+
+func TestTagSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Tag with snippets", "/tags/haiku", http.StatusOK, []byte("An old silent pond")},
+		{"Tag without snippets", "/tags/go", http.StatusOK, []byte("There's nothing to see here yet!")},
+		{"Invalid tag", "/tags/Not%20A%20Tag", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body to contain %q", test.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		title      string
+		content    string
+		tags       string
+		visibility string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Valid submission", "Title", "Content", "go, http", "public", http.StatusSeeOther, nil},
+		{"Empty title", "", "Content", "", "public", http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid tag", "Title", "Content", "c++", "public", http.StatusOK, []byte("is invalid")},
+		{"Too many tags", "Title", "Content", "a, b, c, d, e, f", "public", http.StatusOK, []byte("too many tags")},
+		{"Invalid visibility", "Title", "Content", "", "secret", http.StatusOK, []byte("This field is invalid")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", test.title)
+			form.Add("content", test.content)
+			form.Add("expires", "7")
+			form.Add("tags", test.tags)
+			form.Add("visibility", test.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowPrivateSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+	}{
+		{"Anonymous", "", http.StatusNotFound},
+		{"Other user", "carol@example.com", http.StatusNotFound},
+		{"Owner", "alice@example.com", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if test.email != "" {
+				ts.login(t, test.email)
+			}
+
+			code, _, _ := ts.get(t, "/snippet/3")
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestPublicProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"User with snippets", "/u/1", http.StatusOK, []byte("An old silent pond")},
+		{"User without snippets", "/u/2", http.StatusOK, []byte("There's nothing to see here yet!")},
+		{"Invalid page", "/u/1?page=foo", http.StatusOK, []byte("An old silent pond")},
+		{"Past the last page", "/u/1?page=2", http.StatusNotFound, nil},
+		{"Overflowing page", "/u/1?page=9223372036854775807", http.StatusNotFound, nil},
+		{"Non-existent ID", "/u/99", http.StatusNotFound, nil},
+		{"String ID", "/u/alice", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body to contain %q", test.wantBody)
+			}
+		})
+	}
+}
+
+func TestStarSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	_, _, body := ts.get(t, "/snippet/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantLoc  string
+	}{
+		{"Star", "/snippet/1/star", http.StatusSeeOther, "/snippet/1"},
+		{"Unstar", "/snippet/1/unstar", http.StatusSeeOther, "/snippet/1"},
+		{"Non-existent ID", "/snippet/2/star", http.StatusNotFound, ""},
+		{"Private snippet of other user", "/snippet/3/star", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, test.urlPath, form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want %q; got %q", test.wantLoc, loc)
+			}
+		})
+	}
+}
+
+func TestUserStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	code, _, body := ts.get(t, "/user/stars")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	want := []byte("An old silent pond")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+
+	// The show page offers to unstar a starred snippet.
+	_, _, body = ts.get(t, "/snippet/1")
+	want = []byte("<form action='/snippet/1/unstar' method='POST'")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "carol@example.com")
+
+	// The fork form is pre-filled with the original snippet.
+	code, _, body := ts.get(t, "/snippet/1/fork")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{
+		"<input type='hidden' name='forked_from' value='1'>",
+		"<textarea name='file_content'>An old silent pond...</textarea>",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	// Private snippets of other users cannot be forked.
+	code, _, _ = ts.get(t, "/snippet/3/fork")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+
+	tests := []struct {
+		name       string
+		forkedFrom string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Valid fork", "1", http.StatusSeeOther, nil},
+		{"Invisible parent", "3", http.StatusOK, []byte("The snippet you are forking no longer exists")},
+		{"Non-existent parent", "99", http.StatusOK, []byte("The snippet you are forking no longer exists")},
+		{"Invalid parent", "abc", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Title")
+			form.Add("content", "Content")
+			form.Add("expires", "7")
+			form.Add("visibility", "public")
+			form.Add("forked_from", test.forkedFrom)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowSnippetForks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"Parent lists forks", "/snippet/1", []byte("<a href='/snippet/4'>An old silent pond, revisited</a>")},
+		{"Fork links to parent", "/snippet/4", []byte("Forked from <a href='/snippet/1'>snippet #1</a>")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, body := ts.get(t, test.urlPath)
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowMarkdownSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		code, _, body := ts.get(t, "/snippet/5")
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+		for _, want := range []string{
+			`<div class='markdown'><h1 id="restart">Restart</h1>`,
+			`<pre tabindex="0" style="`,
+		} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("want body %s to contain %q", body, want)
+			}
+		}
+		if bytes.Contains(body, []byte("<script>alert(1)</script>")) {
+			t.Errorf("want body %s not to contain raw HTML", body)
+		}
+	}
+
+	// The second view is served from the cache.
+	if n := app.markdown.Len(); n != 1 {
+		t.Errorf("want %d cached snippets; got %d", 1, n)
+	}
+}
+
+func TestSnippetViews(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The view itself counts, but viewing again right away does not.
+	for _, want := range []string{"43 views", "43 views"} {
+		_, _, body := ts.get(t, "/snippet/1")
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body %s to contain %q", body, want)
+		}
+	}
+
+	// The listings can be sorted by popularity.
+	_, _, body := ts.get(t, "/?sort=popular")
+	want := []byte("<h2>Most Viewed Snippets</h2>")
+	if !bytes.Contains(body, want) {
+		t.Errorf("want body %s to contain %q", body, want)
+	}
+}
+
+func TestSnippetStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Owner", "alice@example.com", "/snippet/1/stats", http.StatusOK, []byte("<td>12</td>")},
+		{"Other user", "carol@example.com", "/snippet/1/stats", http.StatusForbidden, nil},
+		{"Private snippet of other user", "carol@example.com", "/snippet/3/stats", http.StatusNotFound, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			ts.login(t, test.email)
+
+			code, _, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// viewStatsDays is the number of days shown in the view statistics of a snippet.
+const viewStatsDays = 30
+
+// dailyViews holds the number of views of a snippet on a single day.
+type dailyViews struct {
+	Day   time.Time
+	Views int
+	// Percent is the number of views relative to the day with the most views.
+	Percent int
+}
+
+// snippetStats shows the number of views per day of a specific snippet to its owner.
+func (app *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.visibleSnippet(w, r)
+	if !ok {
+		return
+	}
+	if s.UserID == 0 || s.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	counts, err := app.views.PerDay(r.Context(), s.ID, viewStatsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	total, err := app.views.Total(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	total += app.viewCounter.Pending(s.ID)
+
+	// Lay the counts out on one row per day, the latest day first.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats := make([]*dailyViews, viewStatsDays)
+	for i := range stats {
+		stats[i] = &dailyViews{Day: today.AddDate(0, 0, -i)}
+	}
+	max := 0
+	for _, c := range counts {
+		i := int(today.Sub(c.Day.UTC().Truncate(24*time.Hour)) / (24 * time.Hour))
+		if i < 0 || i >= len(stats) {
+			continue
+		}
+		stats[i].Views = c.Count
+		if c.Count > max {
+			max = c.Count
+		}
+	}
+	if max > 0 {
+		for _, d := range stats {
+			d.Percent = 100 * d.Views / max
+		}
+	}
+
+	app.render(w, r, "views.page.tmpl", &templateData{
+		Snippet:   s,
+		ViewStats: stats,
+		Views:     total,
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// webhookLogSize is the number of latest deliveries shown in the log of a webhook.
+const webhookLogSize = 50
+
+// userWebhooks shows the webhooks of the current user and the form to add one.
+func (app *application) userWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, forms.New(nil))
+}
+
+// renderWebhooks renders the webhooks of the current user with the given form for a
+// new webhook.
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	webhooks, err := app.webhooks.ByUser(app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "webhooks.page.tmpl", &templateData{
+		Form:     form,
+		Webhooks: webhooks,
+	})
+}
+
+// createWebhook registers a webhook of the current user with a new random secret.
+func (app *application) createWebhook(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("url")
+	form.MaxLength("url", 2048)
+	form.ValidURL("url")
+	if u, err := url.Parse(form.Get("url")); form.Valid() && err == nil && !publicHost(u.Hostname()) {
+		form.Errors.Add("url", "This field must be a public address")
+	}
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, form)
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	id, err := app.webhooks.Insert(app.session.GetInt(r, "authenticatedUserID"), form.Get("url"), secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Webhook successfully added!")
+	http.Redirect(w, r, fmt.Sprintf("/webhook/%d", id), http.StatusSeeOther)
+}
+
+// showWebhook shows a specific webhook of the current user, with its secret and the
+// log of its latest deliveries.
+func (app *application) showWebhook(w http.ResponseWriter, r *http.Request) {
+	h, ok := app.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(h.ID, webhookLogSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, "webhook.page.tmpl", &templateData{
+		Deliveries: deliveries,
+		Webhook:    h,
+	})
+}
+
+// deleteWebhook deletes a specific webhook of the current user.
+func (app *application) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h, ok := app.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.webhooks.Delete(h.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Webhook has been deleted.")
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// ownedWebhook return the webhook given by the ":id" parameter if it is owned by the
+// current user. Otherwise it writes a 404 and return false.
+func (app *application) ownedWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	h, err := app.webhooks.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if h.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.notFound(w)
+		return nil, false
+	}
+	return h, true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestShowWebhook(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Webhooks", "alice@example.com", "/webhooks", http.StatusOK, []byte("<a href='/webhook/1'>https://chat.example.com/hooks/snippets</a>")},
+		{"Own webhook", "alice@example.com", "/webhook/1", http.StatusOK, []byte("unexpected response status 503 Service Unavailable")},
+		{"Secret", "alice@example.com", "/webhook/1", http.StatusOK, []byte("5f0d3c1e8b7a6924")},
+		{"Webhook of other user", "alice@example.com", "/webhook/2", http.StatusNotFound, nil},
+		{"Non-existent ID", "alice@example.com", "/webhook/99", http.StatusNotFound, nil},
+		{"Anonymous", "", "/webhook/1", http.StatusSeeOther, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			if test.email != "" {
+				ts.login(t, test.email)
+			}
+
+			code, _, body := ts.get(t, test.urlPath)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateWebhook(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/webhooks")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantLoc  string
+		wantBody []byte
+	}{
+		{"Valid", "https://chat.example.com/hooks/2", http.StatusSeeOther, "/webhook/3", nil},
+		{"Empty URL", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid URL", "ftp://example.com", http.StatusOK, "", []byte("This field must be an http or https URL")},
+		{"Localhost", "http://localhost:8080/hook", http.StatusOK, "", []byte("This field must be a public address")},
+		{"Metadata address", "http://169.254.169.254/latest/meta-data/", http.StatusOK, "", []byte("This field must be a public address")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("url", test.url)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/webhooks", form)
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != test.wantLoc {
+				t.Errorf("want location %q; got %q", test.wantLoc, loc)
+			}
+			if !bytes.Contains(body, test.wantBody) {
+				t.Errorf("want body %s to contain %q", body, test.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com")
+
+	_, _, body := ts.get(t, "/webhooks")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Own webhook", "/webhook/1/delete", http.StatusSeeOther},
+		{"Webhook of other user", "/webhook/2/delete", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, _, _ := ts.postForm(t, test.urlPath, url.Values{"csrf_token": {csrfToken}})
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readyDBTimeout is how long /readyz waits for the database to answer a ping.
+const readyDBTimeout = 2 * time.Second
+
+// staleIntervals is the number of intervals without run after which a worker is
+// considered stuck.
+const staleIntervals = 3
+
+// healthz reports that the process is alive. It checks no dependency, so that the
+// process is not restarted because the database is down.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("OK"))
+}
+
+// readyCheck is the result of a readiness check.
+type readyCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// LastRun is the time of the last run of a worker.
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// readyReport is the body of /readyz.
+type readyReport struct {
+	Status string                `json:"status"`
+	Checks map[string]readyCheck `json:"checks"`
+}
+
+// readyz reports whether the server can serve requests: the database answers, the
+// templates are loaded, the background workers run and the server is not shutting down.
+// It responds 503 Service Unavailable if any check fails, with the result of each check.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	report := readyReport{
+		Status: "ready",
+		Checks: map[string]readyCheck{
+			"database":        app.checkDB(r),
+			"templates":       app.checkTemplates(),
+			"views_worker":    app.workers.views.check(time.Now()),
+			"webhooks_worker": app.workers.webhooks.check(time.Now()),
+			"shutdown":        app.checkShutdown(),
+		},
+	}
+
+	status := http.StatusOK
+	for _, c := range report.Checks {
+		if c.Status != "ok" {
+			report.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	app.writeJSON(w, r, status, report)
+}
+
+// checkDB pings the database. The error is only logged, as /readyz is public and the
+// error may reveal the address of the database.
+func (app *application) checkDB(r *http.Request) readyCheck {
+	ctx, cancel := context.WithTimeout(r.Context(), readyDBTimeout)
+	defer cancel()
+
+	if err := app.db.PingContext(ctx); err != nil {
+		app.log(r).Warn("Database is not ready", "error", err.Error())
+		return readyCheck{Status: "failed", Error: "the database is unreachable"}
+	}
+	return readyCheck{Status: "ok"}
+}
+
+// checkTemplates checks that the templates are loaded.
+func (app *application) checkTemplates() readyCheck {
+	if len(app.templateCache) == 0 {
+		return readyCheck{Status: "failed", Error: "no template is loaded"}
+	}
+	return readyCheck{Status: "ok"}
+}
+
+// checkShutdown checks that the server is not shutting down, so that the load
+// balancers stop sending requests while the ones in flight are drained.
+func (app *application) checkShutdown() readyCheck {
+	if app.shuttingDown.Load() {
+		return readyCheck{Status: "failed", Error: "the server is shutting down"}
+	}
+	return readyCheck{Status: "ok"}
+}
+
+// workerStates holds the state of each background worker.
+type workerStates struct {
+	views    workerState
+	webhooks workerState
+}
+
+// workerState is the state of a background worker which runs every interval.
+type workerState struct {
+	mu       sync.Mutex
+	running  bool
+	interval time.Duration
+	started  time.Time
+	lastRun  time.Time
+	lastErr  error
+}
+
+// start records that the worker has started to run every interval.
+func (s *workerState) start(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running, s.interval, s.started = true, interval, time.Now()
+}
+
+// stop records that the worker has stopped.
+func (s *workerState) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+}
+
+// ran records a run of the worker, which failed if err is not nil.
+func (s *workerState) ran(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRun, s.lastErr = time.Now(), err
+}
+
+// check return the readiness of the worker at now. It fails if the worker does not
+// run or has not run for staleIntervals intervals. A failed run is only reported,
+// as the failures of the database are checked on their own.
+func (s *workerState) check(now time.Time) readyCheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := readyCheck{Status: "ok"}
+	if !s.lastRun.IsZero() {
+		lastRun := s.lastRun
+		c.LastRun = &lastRun
+	}
+	if s.lastErr != nil {
+		c.Error = "the last run failed"
+	}
+
+	last := s.lastRun
+	if last.IsZero() {
+		last = s.started
+	}
+	switch {
+	case !s.running:
+		c.Status, c.Error = "failed", "the worker is not running"
+	case now.Sub(last) > staleIntervals*s.interval:
+		c.Status, c.Error = "failed", "the worker has not run since "+last.UTC().Format(time.RFC3339)
+	}
+	return c
+}
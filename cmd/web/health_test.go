@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	app.db = &testDB{err: errors.New("connection refused")}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The process is alive even if the database is down.
+	code, header, body := ts.get(t, "/healthz")
+	if code != http.StatusOK || string(body) != "OK" {
+		t.Errorf("want %d %q; got %d %q", http.StatusOK, "OK", code, body)
+	}
+	if cc := header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("want Cache-Control %q; got %q", "no-store", cc)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(app *application)
+		wantCode    int
+		wantFailed  string
+		wantMessage string
+	}{
+		{"Ready", func(app *application) {}, http.StatusOK, "", ""},
+		{"Database down", func(app *application) {
+			app.db = &testDB{err: errors.New("dial tcp 10.0.0.5:3306: connection refused")}
+		}, http.StatusServiceUnavailable, "database", "the database is unreachable"},
+		{"No templates", func(app *application) {
+			app.templateCache = nil
+		}, http.StatusServiceUnavailable, "templates", "no template is loaded"},
+		{"Worker stopped", func(app *application) {
+			app.workers.webhooks.stop()
+		}, http.StatusServiceUnavailable, "webhooks_worker", "the worker is not running"},
+		{"Shutting down", func(app *application) {
+			app.shuttingDown.Store(true)
+		}, http.StatusServiceUnavailable, "shutdown", "the server is shutting down"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.workers.views.start(viewFlushInterval)
+			app.workers.webhooks.start(webhookInterval)
+			test.setup(app)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, header, body := ts.get(t, "/readyz")
+			if code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if ct := header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("want Content-Type %q; got %q", "application/json", ct)
+			}
+
+			var report readyReport
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Checks) != 5 {
+				t.Errorf("want 5 checks; got %d", len(report.Checks))
+			}
+			for name, c := range report.Checks {
+				wantStatus := "ok"
+				if name == test.wantFailed {
+					wantStatus = "failed"
+					if c.Error != test.wantMessage {
+						t.Errorf("want %s error %q; got %q", name, test.wantMessage, c.Error)
+					}
+				}
+				if c.Status != wantStatus {
+					t.Errorf("want %s status %q; got %q", name, wantStatus, c.Status)
+				}
+			}
+			wantStatus := "ready"
+			if test.wantFailed != "" {
+				wantStatus = "not ready"
+			}
+			if report.Status != wantStatus {
+				t.Errorf("want status %q; got %q", wantStatus, report.Status)
+			}
+		})
+	}
+}
+
+func TestWorkerStateCheck(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		state      func() *workerState
+		wantStatus string
+		wantError  string
+	}{
+		{"Not started", func() *workerState {
+			return &workerState{}
+		}, "failed", "the worker is not running"},
+		{"Started", func() *workerState {
+			s := &workerState{}
+			s.start(time.Minute)
+			return s
+		}, "ok", ""},
+		{"Failed run", func() *workerState {
+			s := &workerState{}
+			s.start(time.Minute)
+			s.ran(errors.New("deadlock"))
+			return s
+		}, "ok", "the last run failed"},
+		{"Stale", func() *workerState {
+			return &workerState{running: true, interval: time.Minute, started: now.Add(-time.Hour), lastRun: now.Add(-10 * time.Minute)}
+		}, "failed", "the worker has not run since " + now.Add(-10*time.Minute).UTC().Format(time.RFC3339)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := test.state().check(now)
+			if c.Status != test.wantStatus || c.Error != test.wantError {
+				t.Errorf("want %s %q; got %s %q", test.wantStatus, test.wantError, c.Status, c.Error)
+			}
+		})
+	}
+}
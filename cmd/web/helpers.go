@@ -2,19 +2,29 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
 	"time"
 
+	"kerseeeHuang.com/snippetbox/pkg/models"
+
 	"github.com/justinas/nosurf"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// serveError writes error message and stack trace to the errorLog,
-// then sends a generic 500 Internal Server Error response to the user.
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+// serveError logs the error and its stack trace with the logger of the request, then
+// sends a generic 500 Internal Server Error response to the user, with the request ID
+// to quote to the support.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	stack := debug.Stack()
+	app.log(r).Error("Server error", "error", err.Error(), "stack", string(stack))
+	trace := fmt.Sprintf("%s\n%s", err.Error(), stack)
 
 	// Render all error messages and stack trace into a HTTP response if debug mode is set.
 	if app.debug {
@@ -23,7 +33,11 @@ func (app *application) serverError(w http.ResponseWriter, err error) {
 	}
 
 	// Send generic error message as default.
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	msg := http.StatusText(http.StatusInternalServerError)
+	if id := requestID(r); id != "" {
+		msg += "\n\nPlease quote the request ID " + id + " when contacting the support."
+	}
+	http.Error(w, msg, http.StatusInternalServerError)
 }
 
 // clientError sends a specific status code and correspounding description to the user.
@@ -36,6 +50,26 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// writeJSON writes v as the JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// writeText writes s as a plain text response, which browsers must not sniff as HTML.
+func (app *application) writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(s))
+}
+
 // addDefaultData adds the current year to the CurrentYear field,
 // and return the pointer of the struct templateData.
 func (app *application) addDefaultData(td *templateData, r *http.Request) *templateData {
@@ -43,28 +77,44 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 		td = &templateData{}
 	}
 
+	td.AuthenticatedUserID = app.session.GetInt(r, "authenticatedUserID")
 	td.CSRFToken = nosurf.Token(r)
 	td.CurrentYear = time.Now().Year()
 	td.Flash = app.session.PopString(r, "flash")
 	td.IsAuthenticated = app.isAuthenticated(r)
+	td.IsAdmin = app.isAdmin(r)
 	return td
 }
 
 // render renders the html with given name of page and data.
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderPage(w, r, name, app.addDefaultData(td, r))
+}
+
+// renderPage renders the html with given name of page and data, without the default
+// data. It is used by the pages served without session, like the embedded snippets.
+func (app *application) renderPage(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
 	// Retrieve the template set from the cache based on the page name.
 	ts, ok := app.templateCache[name]
 	if !ok {
-		app.serverError(w, fmt.Errorf("The template %s is not exist.", name))
+		app.serverError(w, r, fmt.Errorf("The template %s is not exist.", name))
 		return
 	}
 
 	// Write the template to the buffer first, instead of straight ro the http writer.
 	// This way, we can handle the writing error more effectively
 	buf := new(bytes.Buffer)
-	err := ts.Execute(buf, app.addDefaultData(td, r))
+	start := time.Now()
+	_, span := app.tracer.Start(r.Context(), "render "+name)
+	err := ts.Execute(buf, td)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	app.metrics.renderDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -81,3 +131,149 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// isAdmin return true if current request is from an authenticated admin,
+// otherwise return false.
+func (app *application) isAdmin(r *http.Request) bool {
+	isAdmin, ok := r.Context().Value(contextKeyIsAdmin).(bool)
+	if !ok {
+		return false
+	}
+	return isAdmin
+}
+
+// remoteIP return the IP address of the client of the request, without the port.
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// viewer return the key identifying the viewer of the request when counting views:
+// the user if authenticated, or the IP address otherwise.
+func (app *application) viewer(r *http.Request) string {
+	if app.isAuthenticated(r) {
+		return fmt.Sprintf("user:%d", app.session.GetInt(r, "authenticatedUserID"))
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// listSort return the sort order of a listing of snippets given by the "sort" query
+// parameter, the latest first by default.
+func listSort(r *http.Request) string {
+	if r.URL.Query().Get("sort") == models.SortPopular {
+		return models.SortPopular
+	}
+	return models.SortLatest
+}
+
+// canViewSnippet return true if the snippet can be shown to the user of the request.
+// Private snippets are only shown to their owners.
+func (app *application) canViewSnippet(r *http.Request, s *models.Snippet) bool {
+	if s.Visibility != models.VisibilityPrivate {
+		return true
+	}
+	return app.isAuthenticated(r) && s.UserID != 0 && s.UserID == app.session.GetInt(r, "authenticatedUserID")
+}
+
+// visibleSnippet return the snippet given by the ":id" parameter if the user of the
+// request can see it. Otherwise it writes a 404 or 500 response and return false.
+func (app *application) visibleSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	s, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !app.canViewSnippet(r, s) {
+		app.notFound(w)
+		return nil, false
+	}
+	return s, true
+}
+
+// pagination holds the position of a page in a paginated listing and
+// builds the links to its neighbouring pages.
+type pagination struct {
+	Path  string
+	Query url.Values
+	Page  int
+	Size  int
+	Total int
+}
+
+// maxPage is the greatest page of a listing, so that the offset of a page cannot
+// overflow.
+const maxPage = 100000
+
+// newPagination return a pagination of given page size for the listing at r.URL.
+// The current page is read from the "page" query string parameter. It return false if
+// the page is greater than maxPage.
+func newPagination(r *http.Request, size int) (*pagination, bool) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	query.Del("page")
+
+	return &pagination{Path: r.URL.Path, Query: query, Page: page, Size: size}, page <= maxPage
+}
+
+// InRange return true if the current page is the first one or holds records, that is
+// if it is not past the last page. Total must be set.
+func (p *pagination) InRange() bool {
+	return p.Page == 1 || p.Offset() < p.Total
+}
+
+// Offset return the number of records before the current page.
+func (p *pagination) Offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// HasPrev return true if there is a page before the current one.
+func (p *pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+// HasNext return true if there is a page after the current one.
+func (p *pagination) HasNext() bool {
+	return p.Page*p.Size < p.Total
+}
+
+// PrevURL return the url of the previous page.
+func (p *pagination) PrevURL() string {
+	return p.url(p.Page - 1)
+}
+
+// NextURL return the url of the next page.
+func (p *pagination) NextURL() string {
+	return p.url(p.Page + 1)
+}
+
+// url return the url of the given page with the other query parameters kept.
+func (p *pagination) url(page int) string {
+	query := url.Values{}
+	for k, v := range p.Query {
+		query[k] = v
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if len(query) == 0 {
+		return p.Path
+	}
+	return p.Path + "?" + query.Encode()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// snippetJSON is the JSON representation of a snippet.
+type snippetJSON struct {
+	ID          int         `json:"id"`
+	URL         string      `json:"url"`
+	Title       string      `json:"title"`
+	Content     string      `json:"content"`
+	ContentType string      `json:"content_type"`
+	Revision    int         `json:"revision"`
+	Visibility  string      `json:"visibility"`
+	Tags        []string    `json:"tags"`
+	Files       []*fileJSON `json:"files"`
+	Author      *authorJSON `json:"author"`
+	ForkedFrom  *int        `json:"forked_from"`
+	Created     time.Time   `json:"created"`
+	Expires     time.Time   `json:"expires"`
+}
+
+// fileJSON is the JSON representation of a file in a snippet.
+type fileJSON struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	RawURL  string `json:"raw_url"`
+}
+
+// authorJSON is the JSON representation of the owner of a snippet.
+type authorJSON struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// newSnippetJSON return the JSON representation of s, with absolute URLs on the base URL.
+// The author is null for the snippets created before snippets had owners, and forked_from
+// is null for the snippets which are not forks.
+func (app *application) newSnippetJSON(s *models.Snippet) *snippetJSON {
+	j := &snippetJSON{
+		ID:          s.ID,
+		URL:         app.absoluteURL(fmt.Sprintf("/snippet/%d", s.ID)),
+		Title:       s.Title,
+		Content:     s.Content,
+		ContentType: s.ContentType,
+		Revision:    s.Revision,
+		Visibility:  s.Visibility,
+		Tags:        s.Tags,
+		Files:       []*fileJSON{},
+		Created:     s.Created.UTC(),
+		Expires:     s.Expires.UTC(),
+	}
+	if j.Tags == nil {
+		j.Tags = []string{}
+	}
+	for _, f := range s.Files {
+		j.Files = append(j.Files, &fileJSON{
+			Name:    f.Name,
+			Content: f.Content,
+			RawURL:  app.absoluteURL(fmt.Sprintf("/snippet/%d/raw/%s", s.ID, url.PathEscape(f.Name))),
+		})
+	}
+	if s.UserID != 0 {
+		j.Author = &authorJSON{
+			ID:   s.UserID,
+			Name: s.UserName,
+			URL:  app.absoluteURL(fmt.Sprintf("/u/%d", s.UserID)),
+		}
+	}
+	if s.ForkedFrom != 0 {
+		forkedFrom := s.ForkedFrom
+		j.ForkedFrom = &forkedFrom
+	}
+	return j
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+func TestNewSnippetJSONRawURL(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name     string
+		fileName string
+		want     string
+	}{
+		{"Plain name", "haiku.txt", "https://snippetbox.example.com/snippet/1/raw/haiku.txt"},
+		{"Reserved characters", "a b?c#d/e.txt", "https://snippetbox.example.com/snippet/1/raw/a%20b%3Fc%23d%2Fe.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &models.Snippet{ID: 1, Files: []*models.SnippetFile{{Name: test.fileName}}}
+			if got := app.newSnippetJSON(s).Files[0].RawURL; got != test.want {
+				t.Errorf("want %q; got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// contextKeyLogger is the key of the logger of a request, which carries its fields.
+	contextKeyLogger = contextKey("logger")
+	// contextKeyRequestState is the key of the requestState of a request.
+	contextKeyRequestState = contextKey("requestState")
+)
+
+// newLogger return a logger writing to w in the given format, "json" or "text", from
+// the given level on.
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// log return the logger of the request, which carries the fields of the request, or
+// the application logger outside logRequest.
+func (app *application) log(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(contextKeyLogger).(*slog.Logger); ok {
+		return logger
+	}
+	return app.logger
+}
+
+// withLogFields return r with the given fields added to its logger, if it has one.
+func withLogFields(r *http.Request, args ...interface{}) *http.Request {
+	logger, ok := r.Context().Value(contextKeyLogger).(*slog.Logger)
+	if !ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), contextKeyLogger, logger.With(args...)))
+}
+
+// requestState is what the middlewares and handlers learn about a request on the way
+// down the chain, and the outer middlewares report once it is served.
+type requestState struct {
+	// route is the pattern matched by the request.
+	route string
+	// userID is the id of the authenticated user, or 0 if anonymous.
+	userID int
+}
+
+// withRequestState return the state of the request, and the request carrying it. The
+// state is created by the first middleware asking for it.
+func withRequestState(r *http.Request) (*requestState, *http.Request) {
+	if state, ok := r.Context().Value(contextKeyRequestState).(*requestState); ok {
+		return state, r
+	}
+	state := &requestState{route: unmatchedRoute}
+	return state, r.WithContext(context.WithValue(r.Context(), contextKeyRequestState, state))
+}
+
+// logRequest is a middleware that gives the request a logger with its fields, starting
+// with its ID and trace ID, and logs the request once it is served with its route,
+// user, status, size and latency.
+func (app *application) logRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		state, r := withRequestState(r)
+		logger := app.logger.With("request_id", requestID(r), "method", r.Method, "path", r.URL.Path, "remote_ip", remoteIP(r))
+		if id := traceID(r); id != "" {
+			logger = logger.With("trace_id", id)
+		}
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKeyLogger, logger)))
+
+		level := slog.LevelInfo
+		if sw.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("proto", r.Proto),
+			slog.String("route", state.route),
+			slog.Int("status", sw.Status()),
+			slog.Int64("bytes", sw.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if state.userID != 0 {
+			attrs = append(attrs, slog.Int("user_id", state.userID))
+		}
+		logger.LogAttrs(r.Context(), level, "Request", attrs...)
+	})
+}
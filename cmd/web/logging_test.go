@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logBuffer is a buffer of JSON logs which is safe for concurrent writes.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries return the log entries with the given message.
+func (b *logBuffer) entries(t *testing.T, msg string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log %q: %v", line, err)
+		}
+		if entry["msg"] == msg {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestLogRequest(t *testing.T) {
+	app := newTestApplication(t)
+	logs := &logBuffer{}
+	app.logger = slog.New(slog.NewJSONHandler(logs, nil))
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	_, _, body := ts.get(t, "/snippet/3")
+	ts.get(t, "/no/such/page")
+
+	entries := logs.entries(t, "Request")
+	if len(entries) < 2 {
+		t.Fatalf("want request logs; got %d", len(entries))
+	}
+	snippet, missing := entries[len(entries)-2], entries[len(entries)-1]
+
+	tests := []struct {
+		name  string
+		entry map[string]interface{}
+		field string
+		want  interface{}
+	}{
+		{"Level", snippet, "level", "INFO"},
+		{"Method", snippet, "method", "GET"},
+		{"Path", snippet, "path", "/snippet/3"},
+		{"Route", snippet, "route", "/snippet/:id"},
+		{"Status", snippet, "status", float64(http.StatusOK)},
+		{"Bytes", snippet, "bytes", float64(len(body))},
+		{"User", snippet, "user_id", float64(1)},
+		{"Remote IP", snippet, "remote_ip", "127.0.0.1"},
+		{"Unmatched route", missing, "route", unmatchedRoute},
+		{"Unmatched status", missing, "status", float64(http.StatusNotFound)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.entry[test.field]; got != test.want {
+				t.Errorf("want %s %v; got %v", test.field, test.want, got)
+			}
+		})
+	}
+
+	if _, ok := snippet["latency_ms"].(float64); !ok {
+		t.Errorf("want a latency; got %v", snippet["latency_ms"])
+	}
+	if _, ok := missing["user_id"]; ok {
+		t.Errorf("want no user on anonymous requests; got %v", missing["user_id"])
+	}
+}
+
+func TestServerErrorLog(t *testing.T) {
+	app := newTestApplication(t)
+	logs := &logBuffer{}
+	app.logger = slog.New(slog.NewJSONHandler(logs, nil))
+
+	// A handler failing behind logRequest and a route, as registered in routes.
+	mux := newPatternMux()
+	mux.Get("/snippet/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.serverError(w, r, errors.New("database is gone"))
+	}))
+	rr := httptest.NewRecorder()
+	app.logRequest(mux).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/snippet/1", nil))
+
+	entries := logs.entries(t, "Server error")
+	if len(entries) != 1 {
+		t.Fatalf("want 1 server error log; got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry["level"] != "ERROR" || entry["error"] != "database is gone" || entry["route"] != "/snippet/:id" {
+		t.Errorf("want the error and the fields of the request; got %v", entry)
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "serverError") {
+		t.Errorf("want a stack trace; got %q", stack)
+	}
+
+	requests := logs.entries(t, "Request")
+	if len(requests) != 1 || requests[0]["level"] != "ERROR" {
+		t.Errorf("want the request logged as an error; got %v", requests)
+	}
+}
@@ -1,95 +1,246 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"flag"
 	"html/template"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"kerseeeHuang.com/snippetbox/pkg/config"
+	"kerseeeHuang.com/snippetbox/pkg/markdown"
 	"kerseeeHuang.com/snippetbox/pkg/models"
 	"kerseeeHuang.com/snippetbox/pkg/models/mysql"
 
 	_ "github.com/go-sql-driver/mysql" // We don't explicit need this, but database/sql need this.
 	"github.com/golangcollege/sessions"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
 
-const contextKeyIsAuthenticated = contextKey("isAuthenticated")
+const (
+	contextKeyIsAuthenticated = contextKey("isAuthenticated")
+	contextKeyIsAdmin         = contextKey("isAdmin")
+)
 
 // application holds all the application-wide dependencies.
 type application struct {
-	debug    bool
-	errorLog *log.Logger
-	infoLog  *log.Logger
+	collections interface {
+		Insert(userID int, name, visibility string) (int, error)
+		Get(id int) (*models.Collection, error)
+		ByUser(userID int) ([]*models.Collection, error)
+		Delete(id int) error
+		Snippets(id int) ([]*models.Snippet, error)
+		AddSnippet(id, snippetID int) error
+		RemoveSnippet(id, snippetID int) error
+		MoveSnippet(id, snippetID int, up bool) error
+	}
+
+	comments interface {
+		Insert(snippetID, userID, parentID int, body string) (int, error)
+		Get(id int) (*models.Comment, error)
+		Update(id int, body string) error
+		Delete(id int) error
+		ForSnippet(snippetID int) ([]*models.Comment, error)
+	}
+
+	// baseURL is the public URL of the site, which the absolute links start with.
+	baseURL *url.URL
+
+	// db is the database, which /readyz pings.
+	db interface {
+		PingContext(ctx context.Context) error
+	}
+
+	debug bool
+	// frameAncestors are the CSP sources allowed to embed snippets in frames.
+	frameAncestors string
+	// logger is the application logger. The handlers log with app.log(r) instead, which
+	// adds the fields of the request.
+	logger *slog.Logger
+
+	auditEvents interface {
+		Insert(e *models.AuditEvent) error
+		List(filter models.AuditFilter, limit, offset int) ([]*models.AuditEvent, int, error)
+	}
+
+	// metrics are the metrics served on /metrics to the clients in metricsAllow.
+	metrics      *appMetrics
+	metricsAllow []*net.IPNet
+
+	// markdown caches the rendered HTML of Markdown snippets by revision.
+	markdown *markdown.Cache
 
 	session *sessions.Session
+	// shuttingDown is set once the server starts to shut down, so that /readyz fails.
+	shuttingDown atomic.Bool
 
 	snippets interface {
-		Insert(title, content, expires string) (int, error)
-		Get(id int) (*models.Snippet, error)
-		Latest() ([]*models.Snippet, error)
+		Insert(ctx context.Context, s *models.Snippet, expires string) (int, error)
+		Get(ctx context.Context, id int) (*models.Snippet, error)
+		ByUser(ctx context.Context, userID int, sort string, limit, offset int) ([]*models.Snippet, int, error)
+		Forks(ctx context.Context, id int) ([]*models.Snippet, error)
+		Latest(ctx context.Context, tag, sort string) ([]*models.Snippet, error)
+		FeedModified(ctx context.Context, tag string, userID int) (time.Time, error)
+		List(ctx context.Context, limit, offset int) ([]*models.Snippet, int, error)
+		Find(ctx context.Context, id int) (*models.Snippet, error)
+		Expired(ctx context.Context, limit int) ([]*models.Snippet, error)
+		MarkExpiryNotified(ctx context.Context, id int) error
+		SetHidden(ctx context.Context, id int, hidden bool) error
+		Delete(ctx context.Context, id int) error
+		CreatedPerDay(ctx context.Context, days int) ([]*models.DailyCount, error)
+		ExpiredPerDay(ctx context.Context, days int) ([]*models.DailyCount, error)
+	}
+
+	stars interface {
+		Star(ctx context.Context, userID, snippetID int) error
+		Unstar(ctx context.Context, userID, snippetID int) error
+		IsStarred(ctx context.Context, userID, snippetID int) (bool, error)
+		Count(ctx context.Context, snippetID int) (int, error)
+		ByUser(ctx context.Context, userID int) ([]*models.Snippet, error)
+		MostStarredSince(ctx context.Context, since time.Time, limit int) ([]*models.Snippet, error)
+	}
+
+	tags interface {
+		Popular(limit int) ([]*models.Tag, error)
 	}
 
 	templateCache map[string]*template.Template
 
+	// tracer starts the spans of the requests and renders. The models use the global
+	// tracer provider, which is the same outside the tests.
+	tracer trace.Tracer
+
+	// viewCounter counts the views of snippets until they are flushed to views.
+	viewCounter *viewCounter
+	views       interface {
+		Add(ctx context.Context, day time.Time, counts map[int]int) error
+		Total(ctx context.Context, snippetID int) (int, error)
+		PerDay(ctx context.Context, snippetID, days int) ([]*models.DailyCount, error)
+	}
+
 	users interface {
-		Insert(name, email, password string) error
-		Authenticate(email, password string) (int, error)
-		Get(id int) (*models.User, error)
-		ChangePassword(id int, currentPassword, newPassword string) error
+		Insert(ctx context.Context, name, email, password string) (int, error)
+		Authenticate(ctx context.Context, email, password string) (int, error)
+		Get(ctx context.Context, id int) (*models.User, error)
+		ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) error
+		Search(ctx context.Context, query string, limit, offset int) ([]*models.User, int, error)
+		SetActive(ctx context.Context, id int, active bool) error
+		RequirePasswordReset(ctx context.Context, id int) (string, error)
+		SignupsPerDay(ctx context.Context, days int) ([]*models.DailyCount, error)
+	}
+
+	// workers are the states of the background workers, which /readyz checks.
+	workers workerStates
+
+	// webhookWorker sends the deliveries queued in webhooks.
+	webhookWorker *webhookWorker
+	webhooks      interface {
+		Insert(userID int, url, secret string) (int, error)
+		Get(id int) (*models.Webhook, error)
+		ByUser(userID int) ([]*models.Webhook, error)
+		Delete(id int) error
+		Enqueue(userID int, event string, payload []byte) error
+		Due(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+		RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error
+		Deliveries(webhookID, limit int) ([]*models.WebhookDelivery, error)
 	}
 }
 
 func main() {
-	// Parse the runtime configuration settings for the application.
-	// addr is a flag to set HTTP network address
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	// dsn is a flag to set data source name.
-	dsn := flag.String("dsn", "web:satoshi7442@/snippetbox?parseTime=true", "MySQL data source name")
-	// secret is a flag to set the encrption key and will be used to authenticate session cookies
-	secret := flag.String("secret", "s6Ndh+pPbnzHbS*+9Pk8qGwhTzbpa@ge", "Secret key")
-	// debug is a flag to set if it is in debug mode.
-	debug := flag.Bool("debug", false, "Set true for debug mode")
-	flag.Parse()
+	// Load the runtime configuration settings from the defaults, the config file, the
+	// environment and the flags.
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Establishing the dependencies for the handlers
-	// infoLog is a logger for writing information messages.
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	// errorLog is a logger for writing error messages.
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	// logger writes structured logs to stdout. The config is valid, so are its log
+	// settings and its networks.
+	logger, err := newLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metricsAllow, err := cfg.MetricsNetworks()
+	if err != nil {
+		log.Fatal(err)
+	}
+	baseURL, err := cfg.ParseBaseURL()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Print the effective config, without its secrets.
+	logger.Info("Loaded configuration", "config", cfg)
+
+	// Trace the requests, queries and renders with the configured exporter.
+	tp, shutdownTracing, err := newTracerProvider(cfg)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err.Error())
+		os.Exit(1)
+	}
 
 	// Open the DB.
-	db, err := openDB(*dsn)
+	db, err := openDB(cfg.DSN)
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error("Failed to open the database", "error", err.Error())
+		os.Exit(1)
 	}
-	defer db.Close()
 
 	// Initialize a new template cache.
 	templateCache, err := newTemplateCache()
 	if err != nil {
-		errorLog.Fatal(err)
+		logger.Error("Failed to parse the templates", "error", err.Error())
+		os.Exit(1)
 	}
 
 	// Initialize a session manager and set its lifetime.
-	session := sessions.New([]byte(*secret))
-	session.Lifetime = 12 * time.Hour
+	session := newSession(cfg.Secret, cfg.OldSecrets)
 
 	// Initialize an application to hold all the dependencies and routes (mux).
 	app := &application{
-		debug:         *debug,
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		snippets:      &mysql.SnippetModel{DB: db},
-		templateCache: templateCache,
-		users:         &mysql.UserModel{DB: db},
+		auditEvents:    &mysql.AuditModel{DB: db},
+		baseURL:        baseURL,
+		collections:    &mysql.CollectionModel{DB: db},
+		comments:       &mysql.CommentModel{DB: db},
+		db:             db,
+		debug:          cfg.Debug,
+		frameAncestors: cfg.FrameAncestors,
+		logger:         logger,
+		markdown:       markdown.NewCache(markdownCacheSize),
+		metrics:        newAppMetrics(),
+		metricsAllow:   metricsAllow,
+		session:        session,
+		snippets:       &mysql.SnippetModel{DB: db, Timeout: cfg.QueryTimeout},
+		stars:          &mysql.StarModel{DB: db, Timeout: cfg.QueryTimeout},
+		tags:           &mysql.TagModel{DB: db},
+		templateCache:  templateCache,
+		tracer:         newTracer(tp),
+		users:          &mysql.UserModel{DB: db, Timeout: cfg.QueryTimeout},
+		views:          &mysql.ViewModel{DB: db, Timeout: cfg.QueryTimeout},
+		webhooks:       &mysql.WebhookModel{DB: db},
 	}
+	app.metrics.registerDB(db)
+	app.viewCounter = newViewCounter(app.views, viewWindow)
+	app.webhookWorker = newWebhookWorker(app.webhooks, newWebhookClient())
+
+	// Write the counted views to the database and send the webhook deliveries in the background.
+	stopWorkers := app.runWorkers()
 
 	// Config the curve preferences in TLS.
 	tlsConfig := &tls.Config{
@@ -98,23 +249,59 @@ func main() {
 	}
 
 	// Running the HTTP server.
-	// Initialize the http server with addr, errorLog and handler defined above.
+	// Initialize the http server with addr, logger and handler defined above.
 	// Otherwise the http default server will use stderr to output error.
 	srv := &http.Server{
-		Addr:         *addr,
-		ErrorLog:     errorLog,
+		Addr:         cfg.Addr,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		Handler:      app.routes(), // Create a mux from app.routes()
 		TLSConfig:    tlsConfig,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
-	// Use the http.ListenAndServe() function to start a new web server.
-	// Call Fatal if there is any error.
-	infoLog.Printf("Starting server on %s\n", *addr)
-	// Open a HTTPS server.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	// Shut down gracefully on Ctrl-C and on the SIGTERM sent by deployments.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	// Open a HTTPS server, until it fails or it is shut down.
+	logger.Info("Starting server", "addr", cfg.Addr)
+	err = app.serve(srv, func() error {
+		return srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	}, quit, cfg.DrainDelay, cfg.DrainTimeout)
+
+	// Stop the workers after the last requests, so that their views are written too,
+	// and only then close the DB they use.
+	stopWorkers()
+	if closeErr := db.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	// Flush the last spans.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+	cancel()
+
+	if err != nil {
+		logger.Error("Server stopped", "error", err.Error())
+		os.Exit(1)
+	}
+	logger.Info("Server stopped")
+}
+
+// newSession return the session manager of the secrets. The cookies are written with
+// secret, and read with it or any of the old secrets, so that the secret can be rotated
+// without logging the users out.
+func newSession(secret string, oldSecrets []string) *sessions.Session {
+	oldKeys := make([][]byte, len(oldSecrets))
+	for i, s := range oldSecrets {
+		oldKeys[i] = []byte(s)
+	}
+
+	session := sessions.New([]byte(secret), oldKeys...)
+	session.Lifetime = 12 * time.Hour
+	return session
 }
 
 // openDB wraps sql.Open() and returns a sql.DB connection pool for a given DSN.
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewSession(t *testing.T) {
+	const (
+		oldSecret = "3dSmsje8xh19sj38cnsl2i38Sja29Si2"
+		newSecret = "0123456789abcdef0123456789abcdef"
+	)
+
+	// Write a session cookie with the old secret.
+	old := newSession(oldSecret, nil)
+	rr := httptest.NewRecorder()
+	old.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		old.Put(r, "authenticatedUserID", 1)
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("want 1 cookie; got %d", len(cookies))
+	}
+
+	tests := []struct {
+		name       string
+		oldSecrets []string
+		wantID     int
+	}{
+		{"Old secret kept", []string{oldSecret}, 1},
+		{"Old secret dropped", nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := newSession(newSecret, test.oldSecrets)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(cookies[0])
+
+			var id int
+			session.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id = session.GetInt(r, "authenticatedUserID")
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if id != test.wantID {
+				t.Errorf("want user %d; got %d", test.wantID, id)
+			}
+		})
+	}
+}
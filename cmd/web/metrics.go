@@ -0,0 +1,233 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bmizerany/pat"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the route of the requests which match no pattern.
+const unmatchedRoute = "unmatched"
+
+// appMetrics holds the metrics of the application.
+type appMetrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	renderDuration  *prometheus.HistogramVec
+
+	logins          *prometheus.CounterVec
+	signups         prometheus.Counter
+	snippetsCreated prometheus.Counter
+}
+
+// newAppMetrics return the metrics of the application, registered in a new registry.
+func newAppMetrics() *appMetrics {
+	m := &appMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_http_requests_total",
+			Help: "Number of HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route pattern, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "snippetbox_template_render_duration_seconds",
+			Help:    "Time to render the HTML templates by page.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"template"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snippetbox_logins_total",
+			Help: "Number of login attempts by result.",
+		}, []string{"result"}),
+		signups: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_signups_total",
+			Help: "Number of users signed up.",
+		}),
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "snippetbox_snippets_created_total",
+			Help: "Number of snippets created.",
+		}),
+	}
+	m.registry.MustRegister(m.requests, m.requestDuration, m.renderDuration, m.logins, m.signups, m.snippetsCreated)
+	return m
+}
+
+// registerDB adds the gauges of the connection pool of db.
+func (m *appMetrics) registerDB(db *sql.DB) {
+	gauges := []struct {
+		name, help string
+		value      func(s sql.DBStats) float64
+	}{
+		{"snippetbox_db_max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"snippetbox_db_open_connections", "Number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"snippetbox_db_in_use_connections", "Number of connections to the database in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"snippetbox_db_idle_connections", "Number of idle connections to the database.", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		value := g.value
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: g.name, Help: g.help}, func() float64 { return value(db.Stats()) }))
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(s sql.DBStats) float64
+	}{
+		{"snippetbox_db_wait_count_total", "Number of connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"snippetbox_db_wait_duration_seconds_total", "Time spent waiting for connections.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"snippetbox_db_max_idle_closed_total", "Number of connections closed because of the maximum of idle connections.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"snippetbox_db_max_idle_time_closed_total", "Number of connections closed because of the maximum idle time.", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"snippetbox_db_max_lifetime_closed_total", "Number of connections closed because of the maximum lifetime.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		value := c.value
+		m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{Name: c.name, Help: c.help}, func() float64 { return value(db.Stats()) }))
+	}
+}
+
+// metricMethod return the method of the request as a label value: one of the standard
+// methods, or "other", so that a client cannot add series with made-up methods.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// instrument is a middleware that counts the requests and measures their latency by
+// route pattern and status. It must come before recoverPanic to see the 500 responses
+// of panics.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		state, r := withRequestState(r)
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		method, status := metricMethod(r.Method), strconv.Itoa(sw.Status())
+		app.metrics.requests.WithLabelValues(state.route, method, status).Inc()
+		app.metrics.requestDuration.WithLabelValues(state.route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter records the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status and sends it.
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write sends b, after the 200 status if no status was sent.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Status return the status of the response, which is 200 if the handler sent nothing.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap return the wrapped ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// patternMux is a pat mux whose handlers record their pattern as the route of the
+// request, so that the metrics and logs are not split by ids.
+type patternMux struct {
+	*pat.PatternServeMux
+	// routes are the method and the pattern of the registered handlers, such as
+	// "GET /snippet/:id", in the order of registration.
+	routes []string
+}
+
+// newPatternMux return an empty patternMux.
+func newPatternMux() *patternMux {
+	return &patternMux{PatternServeMux: pat.New()}
+}
+
+// Get registers h for GET and HEAD requests matching pattern.
+func (m *patternMux) Get(pattern string, h http.Handler) {
+	m.routes = append(m.routes, http.MethodGet+" "+pattern)
+	m.PatternServeMux.Get(pattern, withRoute(pattern, h))
+}
+
+// Post registers h for POST requests matching pattern.
+func (m *patternMux) Post(pattern string, h http.Handler) {
+	m.routes = append(m.routes, http.MethodPost+" "+pattern)
+	m.PatternServeMux.Post(pattern, withRoute(pattern, h))
+}
+
+// withRoute return a handler which records pattern as the route of the request.
+func withRoute(pattern string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, r := withRequestState(r)
+		state.route = pattern
+		h.ServeHTTP(w, withLogFields(r, "route", pattern))
+	})
+}
+
+// metricsHandler return the handler serving the metrics in the Prometheus format to the
+// clients allowed by metricsAllow. Other clients get a 404, as if there were no metrics.
+func (app *application) metricsHandler() http.Handler {
+	h := promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.metricsAllowed(r) {
+			app.notFound(w)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// metricsAllowed reports whether the client of the request may read the metrics. The
+// client is the peer of the connection, so the check assumes that the server is
+// exposed directly: behind a reverse proxy every request comes from the proxy, and the
+// proxy must not be allowed or must not forward /metrics.
+func (app *application) metricsAllowed(r *http.Request) bool {
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, network := range app.metricsAllow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, "/snippet/1")
+	ts.get(t, "/snippet/3")
+	ts.get(t, "/no/such/page")
+	ts.login(t, "alice@example.com")
+
+	code, header, body := ts.get(t, "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if got := header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("want the Prometheus text format; got %q", got)
+	}
+
+	for _, want := range []string{
+		`snippetbox_http_requests_total{method="GET",route="/snippet/:id",status="200"} 1`,
+		`snippetbox_http_requests_total{method="GET",route="/snippet/:id",status="404"} 1`,
+		`snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`snippetbox_http_requests_total{method="POST",route="/user/login",status="303"} 1`,
+		`snippetbox_http_request_duration_seconds_count{method="GET",route="/snippet/:id",status="200"} 1`,
+		`snippetbox_template_render_duration_seconds_count{template="show.page.tmpl"} 1`,
+		`snippetbox_logins_total{result="success"} 1`,
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestMetricsAllow(t *testing.T) {
+	app := newTestApplication(t)
+	app.metricsAllow = nil
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/metrics")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+	if bytes.Contains(body, []byte("snippetbox_")) {
+		t.Error("want no metrics in body")
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		handler    http.HandlerFunc
+		wantRoute  string
+		wantMethod string
+		wantStatus string
+	}{
+		{"Implicit OK", http.MethodGet, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) }, "/snippet/:id", http.MethodGet, "200"},
+		{"Status", http.MethodGet, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }, "/snippet/:id", http.MethodGet, "418"},
+		{"Panic", http.MethodGet, func(w http.ResponseWriter, r *http.Request) { panic("oops") }, "/snippet/:id", http.MethodGet, "500"},
+		{"Unknown method", "PURGE", func(w http.ResponseWriter, r *http.Request) {}, unmatchedRoute, "other", "405"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			mux := newPatternMux()
+			mux.Get("/snippet/:id", test.handler)
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, "/snippet/1", nil)
+			app.instrument(app.recoverPanic(mux)).ServeHTTP(rr, r)
+
+			if n := testutil.CollectAndCount(app.metrics.requests); n != 1 {
+				t.Errorf("want 1 series; got %d", n)
+			}
+			if got := testutil.ToFloat64(app.metrics.requests.WithLabelValues(test.wantRoute, test.wantMethod, test.wantStatus)); got != 1 {
+				t.Errorf("want 1 %s request to %s with status %s; got %v", test.wantMethod, test.wantRoute, test.wantStatus, got)
+			}
+		})
+	}
+}
@@ -21,15 +21,6 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// logRequest is a middleware that write requests information to infoLog.
-func (app *application) logRequest(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // recoverPanic is a middleware that recover the handler from panic
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +30,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// Set connection-close header on the response
 				w.Header().Set("Connection", "close")
 				// Call serverError
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -66,6 +57,19 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin is a middleware that forbids the users who are not admins.
+// It should be chained after requireAuthentication.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdmin(r) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // noSurf is a middleware that wraps the next handler with a customized CSRF cookie.
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
@@ -91,14 +95,14 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Check if this user exists in DB.
-		user, err := app.users.Get(app.session.GetInt(r, "authenticatedUserID"))
+		user, err := app.users.Get(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
 		if errors.Is(err, models.ErrNoRecord) {
 			app.session.Remove(r, "authenticatedUserID")
 			next.ServeHTTP(w, r)
 			return
 		}
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
@@ -109,9 +113,42 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// If an admin forced a password reset, end the sessions from before the reset,
+		// and send the user logged in with the temporary password to change it.
+		// Logging out is still allowed.
+		if user.MustResetPassword {
+			if !app.session.GetBool(r, "temporaryPassword") {
+				app.session.Remove(r, "authenticatedUserID")
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.Path != "/user/change-password" && r.URL.Path != "/user/logout" {
+				app.session.Put(r, "flash", "Please change your password before continuing.")
+				http.Redirect(w, r, "/user/change-password", http.StatusSeeOther)
+				return
+			}
+		}
+
+		// Report the user in the logs of the request.
+		state, r := withRequestState(r)
+		state.userID = user.ID
+		r = withLogFields(r, "user_id", user.ID)
+
 		// Mark the request from this user so that the request indicates it is from an
-		// authenticated and active user.
+		// authenticated and active user, and whether the user is an admin.
 		ctx := context.WithValue(r.Context(), contextKeyIsAuthenticated, true)
+		ctx = context.WithValue(ctx, contextKeyIsAdmin, user.Admin)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// allowFraming is a middleware that lets the sources in app.frameAncestors show the page
+// in a frame, instead of the "X-Frame-Options: deny" set by secureHeaders.
+func (app *application) allowFraming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("X-Frame-Options")
+		w.Header().Set("Content-Security-Policy", "frame-ancestors "+app.frameAncestors)
+
+		next.ServeHTTP(w, r)
+	})
+}
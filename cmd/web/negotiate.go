@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types which handlers can offer to negotiate.
+const (
+	mediaHTML = "text/html"
+	mediaJSON = "application/json"
+	mediaText = "text/plain"
+)
+
+// mediaRange is a media range of an Accept header with its quality.
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept return the media ranges of the given Accept header. Malformed ranges
+// are skipped.
+func parseAccept(header string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype := splitPair(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		ok := true
+		for _, param := range params[1:] {
+			key, value := splitPair(strings.TrimSpace(param), "=")
+			if strings.ToLower(key) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				ok = false
+				break
+			}
+			mr.q = q
+		}
+		if ok {
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+// splitPair splits s around the first sep. The second part is empty if there is no sep.
+func splitPair(s, sep string) (string, string) {
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// quality return the quality of the media type given by the most specific of the ranges
+// matching it, or 0 if none matches.
+func quality(mediaType string, ranges []mediaRange) float64 {
+	typ, subtype := splitPair(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		s := -1
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// negotiate return the media type among offers preferred by the Accept header of r, and
+// adds "Vary: Accept" to the response so that caches keep each representation apart.
+// The first offer is the default, returned when there is no Accept header or when none
+// of the offers is acceptable, so handlers never need to respond 406.
+func negotiate(w http.ResponseWriter, r *http.Request, offers ...string) string {
+	w.Header().Add("Vary", "Accept")
+
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		// Earlier offers win the ties.
+		if q := quality(offer, ranges); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{mediaHTML, mediaJSON, mediaText}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"No header", "", mediaHTML},
+		{"Anything", "*/*", mediaHTML},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", mediaHTML},
+		{"JSON", "application/json", mediaJSON},
+		{"Plain text", "text/plain", mediaText},
+		{"Preferred JSON", "text/plain;q=0.5, application/json", mediaJSON},
+		{"Specific range wins", "text/*;q=0.2, text/plain", mediaText},
+		{"Any text", "text/*", mediaHTML},
+		{"Excluded HTML", "text/html;q=0, */*", mediaJSON},
+		{"Case insensitive", "Application/JSON", mediaJSON},
+		{"Not acceptable", "image/png", mediaHTML},
+		{"Malformed quality", "application/json;q=high, text/plain;q=0.1", mediaText},
+		{"Malformed range", "json, text/plain", mediaText},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+
+			if got := negotiate(w, r, offers...); got != test.want {
+				t.Errorf("want %q; got %q", test.want, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("want Vary %q; got %q", "Accept", got)
+			}
+		})
+	}
+}
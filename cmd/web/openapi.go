@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the API described by the OpenAPI document.
+const apiVersion = "1.0.0"
+
+// apiOperation describes an operation of the API in the OpenAPI document.
+type apiOperation struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	params  []apiParam
+	// responses maps the status codes to their responses.
+	responses map[int]apiResponse
+}
+
+// apiParam describes a path or query parameter of an operation.
+type apiParam struct {
+	name        string
+	in          string
+	description string
+	required    bool
+	schema      map[string]interface{}
+}
+
+// apiResponse describes a response of an operation.
+type apiResponse struct {
+	description string
+	// content maps the media types to their bodies, given either as a value of the Go
+	// type written by the handler, or as a literal schema.
+	content map[string]interface{}
+}
+
+// apiSchemas names the Go types of the JSON bodies, which are defined once in the
+// components of the document and referred to elsewhere.
+var apiSchemas = map[string]interface{}{
+	"Author":      authorJSON{},
+	"File":        fileJSON{},
+	"OEmbed":      oEmbed{},
+	"ReadyReport": readyReport{},
+	"Snippet":     snippetJSON{},
+}
+
+// Schemas of the bodies which are not JSON.
+var (
+	textSchema   = map[string]interface{}{"type": "string"}
+	binarySchema = map[string]interface{}{"type": "string", "format": "binary"}
+)
+
+// idParam is the id of a resource in the path.
+var idParam = apiParam{
+	name:     "id",
+	in:       "path",
+	required: true,
+	schema:   map[string]interface{}{"type": "integer", "minimum": 1},
+}
+
+// notFoundResponse is the response to a missing resource, or a resource the user cannot see.
+var notFoundResponse = apiResponse{description: "The resource does not exist or is private."}
+
+// apiOperations lists the operations of the API, that is the routes meant for scripts
+// rather than browsers. The routes must be registered with the same methods and paths.
+var apiOperations = []apiOperation{
+	{
+		method:  http.MethodGet,
+		path:    "/snippet/{id}",
+		id:      "getSnippet",
+		summary: "Get a snippet. The representation is negotiated with the Accept header.",
+		params:  []apiParam{idParam},
+		responses: map[int]apiResponse{
+			http.StatusOK: {
+				description: "The snippet, or the content of its first file as plain text.",
+				content: map[string]interface{}{
+					mediaJSON: snippetJSON{},
+					mediaText: textSchema,
+					mediaHTML: textSchema,
+				},
+			},
+			http.StatusNotFound: notFoundResponse,
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/snippet/{id}/raw/{name}",
+		id:      "getSnippetFile",
+		summary: "Get the content of a file in a snippet.",
+		params: []apiParam{idParam, {
+			name:     "name",
+			in:       "path",
+			required: true,
+			schema:   map[string]interface{}{"type": "string"},
+		}},
+		responses: map[int]apiResponse{
+			http.StatusOK:       {description: "The content of the file.", content: map[string]interface{}{mediaText: textSchema}},
+			http.StatusNotFound: notFoundResponse,
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/snippet/{id}/download",
+		id:      "downloadSnippet",
+		summary: "Download all the files in a snippet as a ZIP archive.",
+		params:  []apiParam{idParam},
+		responses: map[int]apiResponse{
+			http.StatusOK:       {description: "The ZIP archive.", content: map[string]interface{}{"application/zip": binarySchema}},
+			http.StatusNotFound: notFoundResponse,
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/oembed",
+		id:      "getOEmbed",
+		summary: "Get the oEmbed of a snippet page.",
+		params: []apiParam{
+			{
+				name:        "url",
+				in:          "query",
+				description: "The URL of a snippet page on this site.",
+				required:    true,
+				schema:      map[string]interface{}{"type": "string", "format": "uri"},
+			},
+			{name: "format", in: "query", schema: map[string]interface{}{"type": "string", "enum": []string{"json"}}},
+			{name: "maxwidth", in: "query", schema: map[string]interface{}{"type": "integer", "minimum": 1}},
+			{name: "maxheight", in: "query", schema: map[string]interface{}{"type": "integer", "minimum": 1}},
+		},
+		responses: map[int]apiResponse{
+			http.StatusOK:             {description: "The oEmbed of the snippet.", content: map[string]interface{}{mediaJSON: oEmbed{}}},
+			http.StatusNotFound:       notFoundResponse,
+			http.StatusNotImplemented: {description: "The format is not supported."},
+		},
+	},
+	feedOperation("/feed.atom", "getLatestAtomFeed", "application/atom+xml", "Atom feed of the latest snippets.", nil),
+	feedOperation("/feed.rss", "getLatestRSSFeed", "application/rss+xml", "RSS feed of the latest snippets.", nil),
+	feedOperation("/tags/{tag}/feed.atom", "getTagAtomFeed", "application/atom+xml", "Atom feed of the latest snippets with a tag.", &apiParam{
+		name: "tag", in: "path", required: true, schema: map[string]interface{}{"type": "string"},
+	}),
+	feedOperation("/tags/{tag}/feed.rss", "getTagRSSFeed", "application/rss+xml", "RSS feed of the latest snippets with a tag.", &apiParam{
+		name: "tag", in: "path", required: true, schema: map[string]interface{}{"type": "string"},
+	}),
+	feedOperation("/u/{id}/feed.atom", "getUserAtomFeed", "application/atom+xml", "Atom feed of the latest snippets of a user.", &idParam),
+	feedOperation("/u/{id}/feed.rss", "getUserRSSFeed", "application/rss+xml", "RSS feed of the latest snippets of a user.", &idParam),
+	{
+		method:  http.MethodGet,
+		path:    "/healthz",
+		id:      "getHealthz",
+		summary: "Check that the server is alive. No dependency is checked.",
+		responses: map[int]apiResponse{
+			http.StatusOK: {description: "The server is alive.", content: map[string]interface{}{mediaText: textSchema}},
+		},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/readyz",
+		id:      "getReadyz",
+		summary: "Check that the server can serve requests, with the result of each check.",
+		responses: map[int]apiResponse{
+			http.StatusOK:                 {description: "The server is ready.", content: map[string]interface{}{mediaJSON: readyReport{}}},
+			http.StatusServiceUnavailable: {description: "A check failed.", content: map[string]interface{}{mediaJSON: readyReport{}}},
+		},
+	},
+}
+
+// feedOperation return the operation of a feed, which supports conditional requests.
+func feedOperation(path, id, mediaType, summary string, param *apiParam) apiOperation {
+	op := apiOperation{
+		method:  http.MethodGet,
+		path:    path,
+		id:      id,
+		summary: summary,
+		responses: map[int]apiResponse{
+			http.StatusOK:          {description: "The feed.", content: map[string]interface{}{mediaType: textSchema}},
+			http.StatusNotModified: {description: "The feed has not changed since the ETag given by If-None-Match, or the date given by If-Modified-Since."},
+			http.StatusNotFound:    notFoundResponse,
+		},
+	}
+	if param != nil {
+		op.params = []apiParam{*param}
+	}
+	return op
+}
+
+// openAPI serves the OpenAPI document of the API.
+func (app *application) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	app.writeJSON(w, r, http.StatusOK, openAPIDocument())
+}
+
+// openAPIDocument return the OpenAPI 3.0 document of apiOperations. The schemas of the
+// JSON bodies are generated from the Go types written by the handlers, so that they
+// always match.
+func openAPIDocument() map[string]interface{} {
+	g := newSchemaGenerator(apiSchemas)
+
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		params := []interface{}{}
+		for _, p := range op.params {
+			param := map[string]interface{}{
+				"name":     p.name,
+				"in":       p.in,
+				"required": p.required,
+				"schema":   p.schema,
+			}
+			if p.description != "" {
+				param["description"] = p.description
+			}
+			params = append(params, param)
+		}
+
+		responses := map[string]interface{}{}
+		for status, resp := range op.responses {
+			response := map[string]interface{}{"description": resp.description}
+			if len(resp.content) > 0 {
+				content := map[string]interface{}{}
+				for mediaType, body := range resp.content {
+					schema, ok := body.(map[string]interface{})
+					if !ok {
+						schema = g.schema(reflect.TypeOf(body))
+					}
+					content[mediaType] = map[string]interface{}{"schema": schema}
+				}
+				response["content"] = content
+			}
+			responses[fmt.Sprint(status)] = response
+		}
+
+		item, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = map[string]interface{}{
+			"operationId": op.id,
+			"summary":     op.summary,
+			"parameters":  params,
+			"responses":   responses,
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Snippetbox",
+			"description": "The read API of Snippetbox. Private snippets are never returned to anonymous clients.",
+			"version":     apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.components(),
+		},
+	}
+}
+
+// schemaGenerator generates the JSON schemas of Go types as encoding/json marshals them.
+type schemaGenerator struct {
+	names map[reflect.Type]string
+}
+
+// newSchemaGenerator return a generator which refers to the types of the given values
+// by their names.
+func newSchemaGenerator(named map[string]interface{}) *schemaGenerator {
+	g := &schemaGenerator{names: map[reflect.Type]string{}}
+	for name, v := range named {
+		g.names[reflect.TypeOf(v)] = name
+	}
+	return g
+}
+
+// components return the schemas of the named types.
+func (g *schemaGenerator) components() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	for t, name := range g.names {
+		schemas[name] = g.define(t)
+	}
+	return schemas
+}
+
+// schema return the schema of t, which is a reference if t is named.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	if name, ok := g.names[t]; ok {
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return g.define(t)
+}
+
+// define return the schema of t. Pointers are nullable, and struct fields are required
+// unless they are omitted when empty. It panics on the types it does not support.
+func (g *schemaGenerator) define(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			// OpenAPI 3.0 ignores the siblings of $ref.
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+		}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if f.PkgPath != "" || tag == "-" {
+				continue
+			}
+			name, opts := splitPair(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = g.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		s := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
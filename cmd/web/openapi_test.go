@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validateSchema checks that v, decoded from JSON, matches the schema of the OpenAPI
+// document doc. It supports the subset of schemas that openAPIDocument generates.
+func validateSchema(doc, schema map[string]interface{}, v interface{}, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		components := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		resolved, ok := components[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %q", at, ref)
+		}
+		return validateSchema(doc, resolved, v, at)
+	}
+
+	if v == nil {
+		if schema["nullable"] == true {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not nullable", at)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if err := validateSchema(doc, s.(map[string]interface{}), v, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want an object; got %T", at, v)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		for name, value := range obj {
+			s, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected property %q", at, name)
+				}
+				if s, ok = schema["additionalProperties"].(map[string]interface{}); !ok {
+					continue
+				}
+			}
+			if err := validateSchema(doc, s, value, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want an array; got %T", at, v)
+		}
+		for i, item := range items {
+			if err := validateSchema(doc, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: want a string; got %T", at, v)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: want a date-time; got %q", at, s)
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: want an integer; got %v", at, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: want a number; got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want a boolean; got %T", at, v)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema %v", at, schema)
+	}
+	return nil
+}
+
+// pathParamRX matches the parameters in the path templates of OpenAPI.
+var pathParamRX = regexp.MustCompile(`{([^}]+)}`)
+
+// TestOpenAPI requests an example of each operation in the OpenAPI document, and checks
+// that the route exists and that the response matches the document.
+func TestOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+	app.workers.views.start(viewFlushInterval)
+	app.workers.webhooks.start(webhookInterval)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/openapi.json")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("want OpenAPI 3.0.3; got %v", doc["openapi"])
+	}
+
+	// examples lists requests for the operations, by operation id, which succeed with
+	// the mock models.
+	examples := map[string][]struct {
+		path   string
+		accept string
+	}{
+		"getSnippet":        {{"/snippet/4", mediaJSON}, {"/snippet/4", mediaText}, {"/snippet/1", mediaHTML}},
+		"getSnippetFile":    {{"/snippet/4/raw/splash.txt", ""}},
+		"downloadSnippet":   {{"/snippet/4/download", ""}},
+		"getOEmbed":         {{"/oembed?url=" + url.QueryEscape("https://snippetbox.example.com/snippet/1"), ""}},
+		"getLatestAtomFeed": {{"/feed.atom", ""}},
+		"getLatestRSSFeed":  {{"/feed.rss", ""}},
+		"getTagAtomFeed":    {{"/tags/haiku/feed.atom", ""}},
+		"getTagRSSFeed":     {{"/tags/haiku/feed.rss", ""}},
+		"getUserAtomFeed":   {{"/u/1/feed.atom", ""}},
+		"getUserRSSFeed":    {{"/u/1/feed.rss", ""}},
+		"getHealthz":        {{"/healthz", ""}},
+		"getReadyz":         {{"/readyz", ""}},
+	}
+
+	seen := map[string]bool{}
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method, o := range item.(map[string]interface{}) {
+			op := o.(map[string]interface{})
+			id := op["operationId"].(string)
+			seen[id] = true
+
+			t.Run(id, func(t *testing.T) {
+				// Every parameter in the path is declared.
+				declared := map[string]bool{}
+				for _, p := range op["parameters"].([]interface{}) {
+					param := p.(map[string]interface{})
+					if param["in"] == "path" {
+						declared[param["name"].(string)] = true
+					}
+				}
+				for _, m := range pathParamRX.FindAllStringSubmatch(path, -1) {
+					if !declared[m[1]] {
+						t.Errorf("path parameter %q of %s is not declared", m[1], path)
+					}
+				}
+
+				if method != "get" {
+					t.Fatalf("want only GET operations; got %s", method)
+				}
+				if len(examples[id]) == 0 {
+					t.Fatalf("want an example of operation %s", id)
+				}
+
+				ok := op["responses"].(map[string]interface{})["200"].(map[string]interface{})
+				content := ok["content"].(map[string]interface{})
+				for _, ex := range examples[id] {
+					header := http.Header{}
+					if ex.accept != "" {
+						header.Set("Accept", ex.accept)
+					}
+					code, rsHeader, body := ts.getWithHeader(t, ex.path, header)
+					if code != http.StatusOK {
+						t.Errorf("GET %s: want %d; got %d", ex.path, http.StatusOK, code)
+						continue
+					}
+
+					mediaType, _, err := mime.ParseMediaType(rsHeader.Get("Content-Type"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					media, ok := content[mediaType].(map[string]interface{})
+					if !ok {
+						t.Errorf("GET %s: media type %q is not in the document", ex.path, mediaType)
+						continue
+					}
+					if mediaType != mediaJSON {
+						continue
+					}
+
+					var v interface{}
+					if err := json.Unmarshal(body, &v); err != nil {
+						t.Fatal(err)
+					}
+					if err := validateSchema(doc, media["schema"].(map[string]interface{}), v, "body"); err != nil {
+						t.Errorf("GET %s: the response drifts from the document: %v", ex.path, err)
+					}
+				}
+			})
+		}
+	}
+
+	for id := range examples {
+		if !seen[id] {
+			t.Errorf("operation %s is not in the document", id)
+		}
+	}
+}
+
+func TestAPIRoutesDocumented(t *testing.T) {
+	app := newTestApplication(t)
+
+	// browserRoutes are the GET routes which are not meant for scripts, so that they
+	// are not documented. Form submissions are not meant for scripts either.
+	browserRoutes := map[string]bool{}
+	for _, pattern := range []string{
+		"/", "/about", "/snippet/create", "/snippet/:id/fork", "/embed/:id", "/snippet/:id/stats",
+		"/comment/:id/edit", "/collections", "/collection/:id", "/tags/:tag",
+		"/user/signup", "/user/login", "/user/profile", "/user/change-password", "/user/stars",
+		"/u/:id", "/webhooks", "/webhook/:id",
+		"/admin", "/admin/users", "/admin/snippets", "/admin/audit", "/admin/audit/export",
+		"/api/openapi.json", "/metrics", "/ping", "/static/",
+	} {
+		browserRoutes[pattern] = true
+	}
+
+	// The paths of the document are written "{name}" where pat patterns have ":name".
+	documented := map[string]bool{}
+	paramRX := regexp.MustCompile(`\{(\w+)\}`)
+	for _, op := range apiOperations {
+		documented[op.method+" "+paramRX.ReplaceAllString(op.path, ":$1")] = true
+	}
+
+	registered := map[string]bool{}
+	for _, route := range app.mux().routes {
+		registered[route] = true
+		method, pattern, _ := strings.Cut(route, " ")
+		if method != http.MethodGet || browserRoutes[pattern] {
+			continue
+		}
+		if !documented[route] {
+			t.Errorf("route %s is not in apiOperations", route)
+		}
+	}
+
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("operation %s is not a registered route", route)
+		}
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	doc := openAPIDocument()
+	schema := map[string]interface{}{"$ref": "#/components/schemas/Author"}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"Valid", `{"id": 1, "name": "Alice", "url": "https://example.com/u/1"}`, false},
+		{"Missing property", `{"id": 1, "name": "Alice"}`, true},
+		{"Unexpected property", `{"id": 1, "name": "Alice", "url": "", "email": "alice@example.com"}`, true},
+		{"Wrong type", `{"id": "1", "name": "Alice", "url": ""}`, true},
+		{"Not an object", `[]`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Round-trip the document like a client would read it.
+			b, err := json.Marshal(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			var v interface{}
+			if err := json.Unmarshal([]byte(test.body), &v); err != nil {
+				t.Fatal(err)
+			}
+			err = validateSchema(decoded, schema, v, "body")
+			if (err != nil) != test.wantErr {
+				t.Errorf("want error %t; got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader is the header of the request ID, in requests and responses.
+const requestIDHeader = "X-Request-ID"
+
+// contextKeyRequestID is the key of the ID of a request.
+const contextKeyRequestID = contextKey("requestID")
+
+// requestIDRX matches the request IDs accepted from clients and proxies. Others are
+// replaced, so that they cannot forge log lines or flood them.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// assignRequestID is a middleware that gives the request an ID, the one of the
+// X-Request-ID header if it is valid or a new one, and returns it in the response.
+func assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyRequestID, id)))
+	})
+}
+
+// requestID return the ID of the request, or "" outside assignRequestID.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(contextKeyRequestID).(string)
+	return id
+}
+
+// newRequestID return a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on the supported platforms.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAssignRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{"Missing", "", false},
+		{"Valid", "3f2a9c1e-7b4d-4e0a-9d8f-1c2b3a4d5e6f", true},
+		{"Invalid characters", "abc\ninjected log line", false},
+		{"Too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestID(r)
+			})
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.incoming != "" {
+				r.Header.Set(requestIDHeader, test.incoming)
+			}
+			assignRequestID(next).ServeHTTP(rr, r)
+
+			if !requestIDRX.MatchString(got) {
+				t.Fatalf("want a valid request ID; got %q", got)
+			}
+			if kept := got == test.incoming; kept != test.wantKept {
+				t.Errorf("want incoming ID kept %t; got %q", test.wantKept, got)
+			}
+			if header := rr.Header().Get(requestIDHeader); header != got {
+				t.Errorf("want header %q; got %q", got, header)
+			}
+		})
+	}
+}
+
+func TestRequestIDOnServerError(t *testing.T) {
+	app := newTestApplication(t)
+	logs := &logBuffer{}
+	app.logger = slog.New(slog.NewJSONHandler(logs, nil))
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(requestIDHeader, "support-1234")
+	assignRequestID(app.logRequest(app.recoverPanic(panicking))).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+	body, _ := io.ReadAll(rs.Body)
+	if rs.StatusCode != http.StatusInternalServerError {
+		t.Errorf("want %d; got %d", http.StatusInternalServerError, rs.StatusCode)
+	}
+	if !strings.Contains(string(body), "support-1234") {
+		t.Errorf("want the request ID on the error page; got %q", body)
+	}
+
+	for _, msg := range []string{"Server error", "Request"} {
+		entries := logs.entries(t, msg)
+		if len(entries) != 1 || entries[0]["request_id"] != "support-1234" {
+			t.Errorf("want the request ID in the %q log; got %v", msg, entries)
+		}
+	}
+}
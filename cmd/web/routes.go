@@ -5,23 +5,33 @@ import (
 
 	"kerseeeHuang.com/snippetbox/ui"
 
-	"github.com/bmizerany/pat"
 	"github.com/justinas/alice"
 )
 
 // routes return a http.Handler that routes all requests to corresponding handlers.
 func (app *application) routes() http.Handler {
-	// Create the standard chain of middleware.
-	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// Create the standard chain of middleware. assignRequestID comes first so that all
+	// the others see the request ID, then traceRequest so that the logs see the trace ID,
+	// then logRequest and instrument to see the whole request, including the panics
+	// recovered.
+	standardMiddleware := alice.New(assignRequestID, app.traceRequest, app.logRequest, app.instrument, app.recoverPanic, secureHeaders)
 
+	return standardMiddleware.Then(app.mux())
+}
+
+// mux return the mux of all the routes, without the standard middlewares.
+func (app *application) mux() *patternMux {
 	// dynamicMiddleware is a chan that contains all middleware specific to dynamic application routes
 	dynamicMiddleware := alice.New(app.session.Enable, noSurf, app.authenticate)
 
 	// authenticatedMiddleware is a chan for pages needed user authentication
 	authenticatedMiddleware := dynamicMiddleware.Append(app.requireAuthentication)
 
-	// Create a mux with third-party package.
-	mux := pat.New()
+	// adminMiddleware is a chan for pages only for admins.
+	adminMiddleware := authenticatedMiddleware.Append(app.requireAdmin)
+
+	// Create a mux with third-party package, which records the route patterns for the metrics.
+	mux := newPatternMux()
 	// Register handlers with the allowed method. The order of statement below MATTERS!
 	// Pat will match patterns in the order that these handler are registered.
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
@@ -29,6 +39,35 @@ func (app *application) routes() http.Handler {
 	mux.Get("/snippet/create", authenticatedMiddleware.ThenFunc(app.createSnippetForm))
 	mux.Post("/snippet/create", authenticatedMiddleware.ThenFunc(app.createSnippet))
 	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/snippet/:id/fork", authenticatedMiddleware.ThenFunc(app.forkSnippetForm))
+	mux.Get("/snippet/:id/raw/:name", dynamicMiddleware.ThenFunc(app.rawSnippetFile))
+	mux.Get("/embed/:id", alice.New(app.allowFraming).ThenFunc(app.embedSnippet))
+	mux.Get("/oembed", http.HandlerFunc(app.oEmbed))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Post("/snippet/:id/star", authenticatedMiddleware.ThenFunc(app.starSnippet))
+	mux.Post("/snippet/:id/unstar", authenticatedMiddleware.ThenFunc(app.unstarSnippet))
+	mux.Get("/snippet/:id/stats", authenticatedMiddleware.ThenFunc(app.snippetStats))
+	mux.Post("/snippet/:id/collect", authenticatedMiddleware.ThenFunc(app.addToCollection))
+	mux.Post("/snippet/:id/comments", authenticatedMiddleware.ThenFunc(app.createComment))
+	mux.Get("/comment/:id/edit", authenticatedMiddleware.ThenFunc(app.editCommentForm))
+	mux.Post("/comment/:id/edit", authenticatedMiddleware.ThenFunc(app.editComment))
+	mux.Post("/comment/:id/delete", authenticatedMiddleware.ThenFunc(app.deleteComment))
+	mux.Get("/collections", authenticatedMiddleware.ThenFunc(app.userCollections))
+	mux.Post("/collections", authenticatedMiddleware.ThenFunc(app.createCollection))
+	mux.Get("/collection/:id", dynamicMiddleware.ThenFunc(app.showCollection))
+	mux.Post("/collection/:id/delete", authenticatedMiddleware.ThenFunc(app.deleteCollection))
+	mux.Post("/collection/:id/remove", authenticatedMiddleware.ThenFunc(app.removeFromCollection))
+	mux.Post("/collection/:id/move", authenticatedMiddleware.ThenFunc(app.moveInCollection))
+	mux.Get("/tags/:tag", dynamicMiddleware.ThenFunc(app.tagSnippets))
+
+	// Add routes about feeds. Feeds are read by feed readers without cookies, so they do
+	// not need sessions.
+	mux.Get("/feed.atom", http.HandlerFunc(app.latestFeed))
+	mux.Get("/feed.rss", http.HandlerFunc(app.latestFeed))
+	mux.Get("/tags/:tag/feed.atom", http.HandlerFunc(app.tagFeed))
+	mux.Get("/tags/:tag/feed.rss", http.HandlerFunc(app.tagFeed))
+	mux.Get("/u/:id/feed.atom", http.HandlerFunc(app.userFeed))
+	mux.Get("/u/:id/feed.rss", http.HandlerFunc(app.userFeed))
 
 	// Add routes about user authentication.
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
@@ -39,15 +78,45 @@ func (app *application) routes() http.Handler {
 	mux.Get("/user/profile", authenticatedMiddleware.ThenFunc(app.userProfile))
 	mux.Get("/user/change-password", authenticatedMiddleware.ThenFunc(app.changePasswordForm))
 	mux.Post("/user/change-password", authenticatedMiddleware.ThenFunc(app.changePassword))
+	mux.Get("/user/stars", authenticatedMiddleware.ThenFunc(app.userStars))
+	mux.Get("/u/:id", dynamicMiddleware.ThenFunc(app.publicProfile))
+	mux.Get("/webhooks", authenticatedMiddleware.ThenFunc(app.userWebhooks))
+	mux.Post("/webhooks", authenticatedMiddleware.ThenFunc(app.createWebhook))
+	mux.Get("/webhook/:id", authenticatedMiddleware.ThenFunc(app.showWebhook))
+	mux.Post("/webhook/:id/delete", authenticatedMiddleware.ThenFunc(app.deleteWebhook))
+
+	// Add routes about site moderation.
+	mux.Get("/admin", adminMiddleware.ThenFunc(app.adminDashboard))
+	mux.Get("/admin/users", adminMiddleware.ThenFunc(app.adminUsers))
+	mux.Post("/admin/users/:id/activate", adminMiddleware.ThenFunc(app.adminActivateUser))
+	mux.Post("/admin/users/:id/deactivate", adminMiddleware.ThenFunc(app.adminDeactivateUser))
+	mux.Post("/admin/users/:id/reset-password", adminMiddleware.ThenFunc(app.adminResetPassword))
+	mux.Get("/admin/snippets", adminMiddleware.ThenFunc(app.adminSnippets))
+	mux.Post("/admin/snippets/:id/hide", adminMiddleware.ThenFunc(app.adminHideSnippet))
+	mux.Post("/admin/snippets/:id/unhide", adminMiddleware.ThenFunc(app.adminUnhideSnippet))
+	mux.Post("/admin/snippets/:id/delete", adminMiddleware.ThenFunc(app.adminDeleteSnippet))
+	mux.Get("/admin/audit", adminMiddleware.ThenFunc(app.adminAudit))
+	mux.Get("/admin/audit/export", adminMiddleware.ThenFunc(app.adminAuditExport))
+
+	// Add the OpenAPI document of the routes meant for scripts.
+	mux.Get("/api/openapi.json", http.HandlerFunc(app.openAPI))
+
+	// Add the metrics for Prometheus. Only the allowed clients can read them.
+	mux.Get("/metrics", app.metricsHandler())
 
 	// Add ping just for test.
 	mux.Get("/ping", http.HandlerFunc(ping))
 
+	// Add the health checks: /healthz while the process is alive, and /readyz while it
+	// can serve requests.
+	mux.Get("/healthz", http.HandlerFunc(healthz))
+	mux.Get("/readyz", http.HandlerFunc(app.readyz))
+
 	// fileServer serve the static files in ./ut/static directory from the ui.Files
 	// embedded file system.
 	fileServer := http.FileServer(neuteredFileSystem{http.FS(ui.Files)})
 	// Handle all the request with /static/ prefix.
 	mux.Get("/static/", fileServer)
 
-	return standardMiddleware.Then(mux)
+	return mux
 }
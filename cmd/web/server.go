@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// serve runs srv with listen until it fails or a signal arrives on quit. Then it shuts
+// srv down gracefully: /readyz fails at once, and after drainDelay, which leaves the
+// load balancers the time to notice, it stops accepting connections and waits up to
+// drainTimeout for the requests in flight to finish, before closing the remaining
+// connections. It return nil if srv has been drained in time.
+func (app *application) serve(srv *http.Server, listen func() error, quit <-chan os.Signal, drainDelay, drainTimeout time.Duration) error {
+	shutdownErr := make(chan error, 1)
+	go func() {
+		sig, ok := <-quit
+		if !ok {
+			return
+		}
+		app.shuttingDown.Store(true)
+		if drainDelay > 0 {
+			app.logger.Info("Waiting for the load balancers to stop sending requests", "signal", sig.String(), "delay", drainDelay.String())
+			time.Sleep(drainDelay)
+		}
+		app.logger.Info("Draining the requests in flight", "signal", sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			// Cut the requests which are still running.
+			srv.Close()
+			err = fmt.Errorf("shutdown: requests still running after %s: %w", drainTimeout, err)
+		}
+		shutdownErr <- err
+	}()
+
+	// Shutdown makes listen return ErrServerClosed at once, without waiting for the
+	// requests in flight, so wait for the shutdown itself.
+	err := listen()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
+
+// runWorkers starts the background workers of the application. It return a function
+// which stops them and waits for them to finish their last work, such as writing the
+// views counted in memory to the database.
+func (app *application) runWorkers() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	app.workers.views.start(viewFlushInterval)
+	go func() {
+		defer wg.Done()
+		defer app.workers.views.stop()
+		app.flushViews(ctx, viewFlushInterval)
+	}()
+	app.workers.webhooks.start(webhookInterval)
+	go func() {
+		defer wg.Done()
+		defer app.workers.webhooks.stop()
+		app.runWebhooks(ctx, webhookInterval)
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// blockingServer return a server whose handler signals started and then blocks until
+// release is closed, and a listener for it.
+func blockingServer(t *testing.T, started chan<- struct{}, release <-chan struct{}) (*http.Server, net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("OK"))
+	})}
+	return srv, ln
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name         string
+		drainDelay   time.Duration
+		drainTimeout time.Duration
+		wantErr      bool
+		wantCode     int
+	}{
+		{"Drained", 0, time.Second, false, http.StatusOK},
+		{"Drained after delay", 30 * time.Millisecond, time.Second, false, http.StatusOK},
+		{"Drain timeout", 0, 50 * time.Millisecond, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			started, release := make(chan struct{}), make(chan struct{})
+			srv, ln := blockingServer(t, started, release)
+			quit := make(chan os.Signal, 1)
+
+			served := make(chan error, 1)
+			go func() {
+				served <- app.serve(srv, func() error { return srv.Serve(ln) }, quit, test.drainDelay, test.drainTimeout)
+			}()
+
+			// Send a request which is still in flight when the signal arrives.
+			codes := make(chan int, 1)
+			go func() {
+				rs, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					codes <- 0
+					return
+				}
+				rs.Body.Close()
+				codes <- rs.StatusCode
+			}()
+			<-started
+			quit <- syscall.SIGTERM
+
+			if test.wantErr {
+				// Let the drain time out before releasing the request.
+				if err := <-served; err == nil {
+					t.Error("want an error")
+				}
+				close(release)
+			} else {
+				// Give the shutdown a moment to start before releasing the request.
+				time.Sleep(20 * time.Millisecond)
+				close(release)
+				if err := <-served; err != nil {
+					t.Errorf("want no error; got %v", err)
+				}
+			}
+
+			if code := <-codes; code != test.wantCode {
+				t.Errorf("want %d; got %d", test.wantCode, code)
+			}
+			if !app.shuttingDown.Load() {
+				t.Error("want the app to be shutting down")
+			}
+		})
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	app := newTestApplication(t)
+	wantErr := errors.New("address already in use")
+
+	err := app.serve(&http.Server{}, func() error { return wantErr }, make(chan os.Signal), 0, time.Second)
+	if !errors.Is(err, wantErr) {
+		t.Errorf("want %v; got %v", wantErr, err)
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	app := newTestApplication(t)
+	store := &viewStore{added: map[time.Time]map[int]int{}}
+	app.viewCounter = newViewCounter(store, viewWindow)
+
+	stop := app.runWorkers()
+	app.viewCounter.Record(1, "ip:10.0.0.1", time.Now())
+	stop()
+
+	// The views counted before stopping are written.
+	total := 0
+	for _, counts := range store.added {
+		total += counts[1]
+	}
+	if total != 1 {
+		t.Errorf("want %d view written; got %d", 1, total)
+	}
+}
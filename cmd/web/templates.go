@@ -7,20 +7,55 @@ import (
 	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/forms"
+	"kerseeeHuang.com/snippetbox/pkg/markdown"
 	"kerseeeHuang.com/snippetbox/pkg/models"
 	"kerseeeHuang.com/snippetbox/ui"
 )
 
 // templateData store snippets that we want to render with html templates.
 type templateData struct {
-	CSRFToken       string
-	CurrentYear     int
+	AuditActions []string
+	AuditEvents  []*models.AuditEvent
+	// AuthenticatedUserID is the id of the current user, or 0 if anonymous.
+	AuthenticatedUserID int
+	Collection          *models.Collection
+	Collections         []*models.Collection
+	Comment             *models.Comment
+	Comments            []*models.Comment
+	CSRFToken           string
+	CurrentYear         int
+	Deliveries          []*models.WebhookDelivery
+	// Feed is the path of the feeds of the page without extension, such as "/feed".
+	Feed string
+	// Files are the rows of files in the snippet form.
+	Files           []*models.SnippetFile
 	Flash           string
+	Forks           []*models.Snippet
 	Form            *forms.Form
+	IsAdmin         bool
 	IsAuthenticated bool
+	MostStarred     []*models.Snippet
+	// OEmbed is the URL of the oEmbed of the page, if it can be embedded.
+	OEmbed     string
+	Pagination *pagination
+	Query      string
+	// RenderedContent is the HTML of the snippet content, if it is rendered as Markdown.
+	RenderedContent template.HTML
 	Snippet         *models.Snippet
 	Snippets        []*models.Snippet
-	User            *models.User
+	// Sort is the sort order of the listed snippets, one of the models.Sort constants.
+	Sort      string
+	Stars     int
+	Starred   bool
+	Stats     []*dailyStats
+	Tag       string
+	Tags      []*models.Tag
+	User      *models.User
+	Users     []*models.User
+	ViewStats []*dailyViews
+	Views     int
+	Webhook   *models.Webhook
+	Webhooks  []*models.Webhook
 }
 
 // humanDate return a nicely formatted string of time.
@@ -32,10 +67,47 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// isPast return true if t is before now.
+func isPast(t time.Time) bool {
+	return t.Before(time.Now())
+}
+
+// tagWeight return the weight of the tag in a tag cloud from 1 to 4, relative to the
+// most used tag in the cloud.
+func tagWeight(tag *models.Tag, cloud []*models.Tag) int {
+	max := 0
+	for _, t := range cloud {
+		if t.Count > max {
+			max = t.Count
+		}
+	}
+	if max == 0 {
+		return 1
+	}
+	return 1 + 3*tag.Count/max
+}
+
+// commentView is the data of the "comment" template: a comment and the page showing it.
+type commentView struct {
+	Comment *models.Comment
+	Page    *templateData
+}
+
+// commentData pack the page data and a comment for the "comment" template.
+func commentData(page *templateData, c *models.Comment) *commentView {
+	return &commentView{Comment: c, Page: page}
+}
+
 // functions store the custom functions used in templates.
 // Template functions should only return one value, or one value and an error.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"canDeleteComment": canDeleteComment,
+	"canEditComment":   canEditComment,
+	"commentData":      commentData,
+	"humanDate":        humanDate,
+	"isPast":           isPast,
+	"markdownComment":  markdown.RenderRestricted,
+	"tagWeight":        tagWeight,
 }
 
 // newTemplateCache create the cache of tamplates with pages in our embedded file system: ui.Files.
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
 	"html"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"sync"
 	"testing"
 	"time"
 
+	"kerseeeHuang.com/snippetbox/pkg/markdown"
+	"kerseeeHuang.com/snippetbox/pkg/models"
 	"kerseeeHuang.com/snippetbox/pkg/models/mock"
 
 	"github.com/golangcollege/sessions"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // csrfTokenRX is a regular expression which captures the CSRF token.
@@ -42,14 +48,93 @@ func newTestApplication(t *testing.T) *application {
 	session.Lifetime = 12 * time.Hour
 	session.Secure = true
 
-	return &application{
-		errorLog:      log.New(io.Discard, "", 0),
-		infoLog:       log.New(io.Discard, "", 0),
-		session:       session,
-		snippets:      &mock.SnippetModel{},
-		templateCache: templateCache,
-		users:         &mock.UserModel{},
+	app := &application{
+		auditEvents:    &mock.AuditModel{},
+		baseURL:        &url.URL{Scheme: "https", Host: "snippetbox.example.com"},
+		collections:    &mock.CollectionModel{},
+		comments:       &mock.CommentModel{},
+		db:             &testDB{},
+		frameAncestors: "https://wiki.example.com",
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		markdown:       markdown.NewCache(markdownCacheSize),
+		metrics:        newAppMetrics(),
+		metricsAllow:   []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(32, 32)}},
+		session:        session,
+		snippets:       &mock.SnippetModel{},
+		stars:          &mock.StarModel{},
+		tags:           &mock.TagModel{},
+		templateCache:  templateCache,
+		tracer:         newTracer(noop.NewTracerProvider()),
+		users:          &mock.UserModel{},
+		views:          &mock.ViewModel{},
+		webhooks:       &mock.WebhookModel{},
 	}
+	app.viewCounter = newViewCounter(app.views, viewWindow)
+	app.webhookWorker = newWebhookWorker(app.webhooks, http.DefaultClient)
+	return app
+}
+
+// testDB is a database whose pings return err.
+type testDB struct {
+	err error
+}
+
+func (db *testDB) PingContext(ctx context.Context) error {
+	return db.err
+}
+
+// auditRecorder is an audit log which keeps the inserted events.
+type auditRecorder struct {
+	mock.AuditModel
+	mu     sync.Mutex
+	events []*models.AuditEvent
+}
+
+func (a *auditRecorder) Insert(e *models.AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+	return nil
+}
+
+// withAction return the recorded events with the given action.
+func (a *auditRecorder) withAction(action string) []*models.AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var events []*models.AuditEvent
+	for _, e := range a.events {
+		if e.Action == action {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// resetUsers is a user model in which the password of alice can be reset.
+type resetUsers struct {
+	mock.UserModel
+	mu    sync.Mutex
+	reset bool
+}
+
+func (u *resetUsers) Get(ctx context.Context, id int) (*models.User, error) {
+	user, err := u.UserModel.Get(ctx, id)
+	if err != nil || id != 1 {
+		return user, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	alice := *user
+	alice.MustResetPassword = u.reset
+	return &alice, nil
+}
+
+func (u *resetUsers) RequirePasswordReset(ctx context.Context, id int) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.reset = true
+	return u.UserModel.RequirePasswordReset(ctx, id)
 }
 
 // testServer is a wrapper of httptest.Server.
@@ -82,7 +167,20 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 // get makes a GET request to a given url on the test server,
 // and return the response status code, headers and body
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byte) {
-	rs, err := ts.Client().Get(ts.URL + urlPath)
+	return ts.getWithHeader(t, urlPath, nil)
+}
+
+// getWithHeader sends GET request with the given header to a given url on the test server.
+func (ts *testServer) getWithHeader(t *testing.T, urlPath string, header http.Header) (int, http.Header, []byte) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rs, err := ts.Client().Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -112,3 +210,18 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, body
 }
+
+// login logs the test server client in as the mock user with given email.
+func (ts *testServer) login(t *testing.T, email string) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", csrfToken)
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s: want %d; got %d", email, http.StatusSeeOther, code)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"kerseeeHuang.com/snippetbox/pkg/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the name of the application in the traces.
+const serviceName = "snippetbox"
+
+// propagator reads the parent span of the requests from their W3C traceparent and
+// tracestate headers.
+var propagator = propagation.TraceContext{}
+
+// newTracer return the tracer of the application from tp.
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	return tp.Tracer("kerseeeHuang.com/snippetbox/cmd/web")
+}
+
+// newTracerProvider return the tracer provider exporting the spans as configured, and
+// sets it as the global one, which the models use. shutdown flushes the last spans and
+// must be called before exiting.
+func newTracerProvider(cfg *config.Config) (tp *sdktrace.TracerProvider, shutdown func(context.Context) error, err error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TraceSampleRatio))),
+	}
+
+	var closer io.Closer
+	switch cfg.TraceExporter {
+	case "none":
+		// Without exporter the spans are still created, so that the logs have trace IDs.
+	case "otlp":
+		exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(cfg.TraceEndpoint))
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case "stdout", "file":
+		w := io.Writer(os.Stdout)
+		if cfg.TraceExporter == "file" {
+			f, err := os.OpenFile(cfg.TraceFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, nil, err
+			}
+			w, closer = f, f
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter %q", cfg.TraceExporter)
+	}
+
+	tp = sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	shutdown = func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}
+	return tp, shutdown, nil
+}
+
+// traceRequest is a middleware that starts the span of the request, as a child of the
+// span of its traceparent header if any. The span is named by the route once the
+// request is served, so that the spans are not split by ids.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, r := withRequestState(r)
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := app.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(remoteIP(r)),
+				attribute.String("request_id", requestID(r)),
+			))
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetName(r.Method + " " + state.route)
+		span.SetAttributes(semconv.HTTPRoute(state.route), semconv.HTTPResponseStatusCode(sw.Status()))
+		if state.userID != 0 {
+			span.SetAttributes(semconv.EnduserID(strconv.Itoa(state.userID)))
+		}
+		if sw.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.Status()))
+		}
+	})
+}
+
+// traceID return the ID of the trace of the request, or "" if it has none.
+func traceID(r *http.Request) string {
+	sc := trace.SpanContextFromContext(r.Context())
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
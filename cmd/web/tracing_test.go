@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kerseeeHuang.com/snippetbox/pkg/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestTracer return a tracer recording its spans in the returned exporter.
+func newTestTracer() (trace.Tracer, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return newTracer(tp), exporter
+}
+
+// findSpan return the recorded span with the given name.
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("want span %q; got %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+// spanAttribute return the value of the attribute of the span with the given key.
+func spanAttribute(s tracetest.SpanStub, key string) interface{} {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func TestTraceRequest(t *testing.T) {
+	app := newTestApplication(t)
+	tracer, exporter := newTestTracer()
+	app.tracer = tracer
+	logs := &logBuffer{}
+	app.logger = slog.New(slog.NewJSONHandler(logs, nil))
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com")
+	exporter.Reset()
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ts.getWithHeader(t, "/snippet/3", header)
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "GET /snippet/:id")
+	render := findSpan(t, spans, "render show.page.tmpl")
+	entries := logs.entries(t, "Request")
+	logged := entries[len(entries)-1]["trace_id"]
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"Trace of the traceparent", server.SpanContext.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"Parent of the traceparent", server.Parent.SpanID().String(), "00f067aa0ba902b7"},
+		{"Kind", server.SpanKind, trace.SpanKindServer},
+		{"Route", spanAttribute(server, "http.route"), "/snippet/:id"},
+		{"Status", spanAttribute(server, "http.response.status_code"), int64(http.StatusOK)},
+		{"User", spanAttribute(server, "enduser.id"), "1"},
+		{"Span status", server.Status.Code, codes.Unset},
+		{"Render parent", render.Parent.SpanID(), server.SpanContext.SpanID()},
+		{"Logged trace ID", logged, "4bf92f3577b34da6a3ce929d0e0e4736"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.got != test.want {
+				t.Errorf("want %v; got %v", test.want, test.got)
+			}
+		})
+	}
+}
+
+func TestTraceRequestError(t *testing.T) {
+	app := newTestApplication(t)
+	tracer, exporter := newTestTracer()
+	app.tracer = tracer
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	app.traceRequest(app.recoverPanic(panicking)).ServeHTTP(rr, r)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span; got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name != "GET "+unmatchedRoute {
+		t.Errorf("want name %q; got %q", "GET "+unmatchedRoute, s.Name)
+	}
+	if s.Status.Code != codes.Error {
+		t.Errorf("want error status; got %v", s.Status.Code)
+	}
+	if !s.SpanContext.TraceID().IsValid() || s.Parent.IsValid() {
+		t.Errorf("want a new trace; got trace %s with parent %s", s.SpanContext.TraceID(), s.Parent.SpanID())
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	global := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(global)
+
+	cfg := config.Default()
+	cfg.TraceExporter = "file"
+	cfg.TraceFile = filepath.Join(t.TempDir(), "traces.json")
+	_, shutdown, err := newTracerProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The models trace with the global tracer provider, so its spans are exported.
+	_, span := otel.Tracer("test").Start(context.Background(), "SnippetModel.Get")
+	span.SetAttributes(attribute.Int("snippet.id", 3))
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(cfg.TraceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name":"SnippetModel.Get"`, `"Value":"snippetbox"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("want the traces to contain %s; got %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// viewWindow is how long repeated views of a snippet by the same viewer count only once.
+const viewWindow = 30 * time.Minute
+
+// viewFlushInterval is how often the counted views are written to the database.
+const viewFlushInterval = time.Minute
+
+// viewRetryAge is how long after the start of their day the views which fail to be
+// written are still retried.
+const viewRetryAge = 48 * time.Hour
+
+// viewKey identifies the views of a snippet by a viewer.
+type viewKey struct {
+	snippetID int
+	viewer    string
+}
+
+// viewCounter counts the views of snippets in memory, so that they can be written to
+// the database in batches instead of on every view. It is safe for concurrent use.
+type viewCounter struct {
+	mu     sync.Mutex
+	window time.Duration
+	store  interface {
+		Add(ctx context.Context, day time.Time, counts map[int]int) error
+	}
+	// seen holds the time of the last counted view of each snippet by each viewer.
+	seen map[viewKey]time.Time
+	// pending holds the numbers of views not written yet, by day and by snippet id.
+	pending map[time.Time]map[int]int
+}
+
+// newViewCounter return a counter which writes the views to store, counting the views
+// of a snippet by a viewer at most once in the window.
+func newViewCounter(store interface {
+	Add(ctx context.Context, day time.Time, counts map[int]int) error
+}, window time.Duration) *viewCounter {
+	return &viewCounter{
+		window:  window,
+		store:   store,
+		seen:    map[viewKey]time.Time{},
+		pending: map[time.Time]map[int]int{},
+	}
+}
+
+// Record counts a view of the snippet by the viewer at now, unless the viewer has
+// already viewed it in the window. It return true if the view is counted.
+func (c *viewCounter) Record(snippetID int, viewer string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := viewKey{snippetID, viewer}
+	if last, ok := c.seen[key]; ok && now.Sub(last) < c.window {
+		return false
+	}
+	c.seen[key] = now
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	if c.pending[day] == nil {
+		c.pending[day] = map[int]int{}
+	}
+	c.pending[day][snippetID]++
+	return true
+}
+
+// Pending return the number of views of the snippet which are not written yet.
+func (c *viewCounter) Pending(snippetID int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, counts := range c.pending {
+		n += counts[snippetID]
+	}
+	return n
+}
+
+// Flush writes the pending views to the store and forgets the viewers whose window has
+// passed at now. The views which fail to be written are kept for the next flush, unless
+// their day is more than viewRetryAge before now, so that an outage of the database
+// can't grow them without bound. It return the number of views dropped that way.
+func (c *viewCounter) Flush(ctx context.Context, now time.Time) (dropped int, err error) {
+	// Swap the pending views out, so that views can still be recorded while writing.
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[time.Time]map[int]int{}
+	for key, last := range c.seen {
+		if now.Sub(last) >= c.window {
+			delete(c.seen, key)
+		}
+	}
+	c.mu.Unlock()
+
+	for day, counts := range pending {
+		addErr := c.store.Add(ctx, day, counts)
+		if addErr == nil {
+			continue
+		}
+		if err == nil {
+			err = addErr
+		}
+
+		if now.Sub(day) > viewRetryAge {
+			for _, n := range counts {
+				dropped += n
+			}
+			continue
+		}
+
+		// Put the views back to retry them later.
+		c.mu.Lock()
+		if c.pending[day] == nil {
+			c.pending[day] = map[int]int{}
+		}
+		for id, n := range counts {
+			c.pending[day][id] += n
+		}
+		c.mu.Unlock()
+	}
+	return dropped, err
+}
+
+// flushViews writes the counted views to the database every interval until ctx is done,
+// and then a last time.
+func (app *application) flushViews(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := app.flushViewCounter(ctx)
+			app.workers.views.ran(err)
+		case <-ctx.Done():
+			// ctx is done, so the last flush is only bounded by the query timeout.
+			app.flushViewCounter(context.Background())
+			return
+		}
+	}
+}
+
+// flushViewCounter flushes the view counter once and logs the failures.
+func (app *application) flushViewCounter(ctx context.Context) error {
+	dropped, err := app.viewCounter.Flush(ctx, time.Now())
+	if err != nil {
+		app.logger.Error("Failed to flush views", "error", err.Error())
+	}
+	if dropped > 0 {
+		app.logger.Warn("Dropped views which failed to be written for too long", "views", dropped)
+	}
+	return err
+}
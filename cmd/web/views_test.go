@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// viewStore records the views added to it, failing if err is set.
+type viewStore struct {
+	added map[time.Time]map[int]int
+	err   error
+}
+
+func (s *viewStore) Add(ctx context.Context, day time.Time, counts map[int]int) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.added[day] == nil {
+		s.added[day] = map[int]int{}
+	}
+	for id, n := range counts {
+		s.added[day][id] += n
+	}
+	return nil
+}
+
+func TestViewCounter(t *testing.T) {
+	store := &viewStore{added: map[time.Time]map[int]int{}}
+	c := newViewCounter(store, 30*time.Minute)
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		snippetID int
+		viewer    string
+		at        time.Time
+		want      bool
+	}{
+		{"First view", 1, "ip:10.0.0.1", now, true},
+		{"Repeated view", 1, "ip:10.0.0.1", now.Add(10 * time.Minute), false},
+		{"Other viewer", 1, "user:1", now.Add(10 * time.Minute), true},
+		{"Other snippet", 2, "ip:10.0.0.1", now.Add(10 * time.Minute), true},
+		{"After the window", 1, "ip:10.0.0.1", now.Add(40 * time.Minute), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := c.Record(test.snippetID, test.viewer, test.at); got != test.want {
+				t.Errorf("want %t; got %t", test.want, got)
+			}
+		})
+	}
+
+	if n := c.Pending(1); n != 3 {
+		t.Errorf("want %d pending views; got %d", 3, n)
+	}
+
+	// Failed writes are kept for the next flush.
+	store.err = errors.New("database is down")
+	if _, err := c.Flush(context.Background(), now.Add(2*time.Hour)); err == nil {
+		t.Error("want an error; got nil")
+	}
+	if n := c.Pending(1); n != 3 {
+		t.Errorf("want %d pending views after a failed flush; got %d", 3, n)
+	}
+
+	store.err = nil
+	if _, err := c.Flush(context.Background(), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Pending(1); n != 0 {
+		t.Errorf("want no pending views after a flush; got %d", n)
+	}
+	if got := store.added[day]; got[1] != 3 || got[2] != 1 {
+		t.Errorf("want 3 views of snippet 1 and 1 of snippet 2; got %v", got)
+	}
+
+	// The viewers whose window has passed are forgotten by the flush.
+	if n := len(c.seen); n != 0 {
+		t.Errorf("want no viewers remembered; got %d", n)
+	}
+}
+
+func TestViewCounterDropsOldViews(t *testing.T) {
+	store := &viewStore{added: map[time.Time]map[int]int{}, err: errors.New("database is down")}
+	c := newViewCounter(store, 30*time.Minute)
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.Record(1, "ip:10.0.0.1", now)
+	c.Record(2, "ip:10.0.0.1", now)
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		wantDropped int
+		wantPending int
+	}{
+		{"Recent failure", now.Add(time.Hour), 0, 1},
+		{"Failure within the retry age", now.Add(viewRetryAge - 13*time.Hour), 0, 1},
+		{"Failure past the retry age", now.Add(viewRetryAge), 2, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dropped, err := c.Flush(context.Background(), test.at)
+			if err == nil {
+				t.Error("want an error; got nil")
+			}
+			if dropped != test.wantDropped {
+				t.Errorf("want %d dropped views; got %d", test.wantDropped, dropped)
+			}
+			if n := c.Pending(1); n != test.wantPending {
+				t.Errorf("want %d pending views; got %d", test.wantPending, n)
+			}
+		})
+	}
+}
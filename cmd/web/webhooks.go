@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// Events sent to webhooks.
+const (
+	webhookSnippetCreated = "snippet.created"
+	webhookSnippetUpdated = "snippet.updated"
+	webhookSnippetDeleted = "snippet.deleted"
+	webhookSnippetExpired = "snippet.expired"
+)
+
+const (
+	// webhookInterval is how often the due deliveries are sent and the expired
+	// snippets are looked for.
+	webhookInterval = 10 * time.Second
+	// webhookTimeout is how long a receiver has to answer a delivery.
+	webhookTimeout = 10 * time.Second
+	// webhookBatchSize is the maximum number of deliveries sent, and of expired
+	// snippets notified, in each round.
+	webhookBatchSize = 20
+	// webhookBackoff is the delay before the first retry. It doubles on each retry.
+	webhookBackoff = time.Minute
+	// webhookMaxAttempts is the number of attempts before a delivery fails for good.
+	webhookMaxAttempts = 8
+	// webhookSignatureHeader is the header of the HMAC-SHA256 signature of the
+	// timestamp and the payload.
+	webhookSignatureHeader = "X-Snippetbox-Signature"
+	// webhookTimestampHeader is the header of the time a delivery is sent, in Unix
+	// seconds, so that receivers can refuse the deliveries replayed later.
+	webhookTimestampHeader = "X-Snippetbox-Timestamp"
+)
+
+var (
+	// errForbiddenAddress is returned when a webhook resolves to an address of the
+	// host or of its private network.
+	errForbiddenAddress = errors.New("webhook: forbidden address")
+	// errWebhookRedirect is returned when a webhook answers with a redirect.
+	errWebhookRedirect = errors.New("webhook: redirects are not followed")
+)
+
+// newWebhookClient return the client sending the deliveries. Since anybody can add a
+// webhook, it only connects to public addresses: the address is checked when dialing,
+// after the name is resolved, so a name resolving to a private address is refused too.
+// Redirects are not followed and no proxy is used, as they would bypass the check.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: publicAddressOnly}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errWebhookRedirect
+		},
+	}
+}
+
+// publicAddressOnly is the Control of the dialer of the webhooks. It refuses to
+// connect to an address which is not public.
+func publicAddressOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublicIP(ip) {
+		return errForbiddenAddress
+	}
+	return nil
+}
+
+// nat64Prefix is the well-known prefix of NAT64 (RFC 6052), whose addresses embed an
+// IPv4 address in their last 32 bits.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// nonPublicPrefixes are the addresses which are not globally reachable, according to
+// the IANA special-purpose address registries.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This network"
+	netip.MustParsePrefix("10.0.0.0/8"),      // Private-Use
+	netip.MustParsePrefix("100.64.0.0/10"),   // Shared Address Space
+	netip.MustParsePrefix("127.0.0.0/8"),     // Loopback
+	netip.MustParsePrefix("169.254.0.0/16"),  // Link Local
+	netip.MustParsePrefix("172.16.0.0/12"),   // Private-Use
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF Protocol Assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation (TEST-NET-1)
+	netip.MustParsePrefix("192.88.99.0/24"),  // Deprecated 6to4 Relay Anycast
+	netip.MustParsePrefix("192.168.0.0/16"),  // Private-Use
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation (TEST-NET-2)
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation (TEST-NET-3)
+	netip.MustParsePrefix("224.0.0.0/4"),     // Multicast
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, and Limited Broadcast
+	netip.MustParsePrefix("::/128"),          // Unspecified Address
+	netip.MustParsePrefix("::1/128"),         // Loopback Address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // IPv4-IPv6 Translation, local use
+	netip.MustParsePrefix("100::/64"),        // Discard-Only Address Block
+	netip.MustParsePrefix("2001::/23"),       // IETF Protocol Assignments, such as Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("fc00::/7"),        // Unique-Local
+	netip.MustParsePrefix("fe80::/10"),       // Link-Local Unicast
+	netip.MustParsePrefix("ff00::/8"),        // Multicast
+}
+
+// isPublicIP return true if ip is in none of nonPublicPrefixes. The IPv4 addresses
+// embedded in IPv4-mapped and NAT64 addresses are checked as IPv4 addresses, since
+// the connections to them end up there.
+func isPublicIP(ip netip.Addr) bool {
+	ip = ip.WithZone("").Unmap()
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		ip = netip.AddrFrom4([4]byte(b[12:]))
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicHost return false if host is obviously not public: localhost or a literal
+// address which is not public. Names are only checked when a delivery is sent.
+func publicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return isPublicIP(ip)
+	}
+	return true
+}
+
+// deliveryError return the error of a failed request to a webhook, as shown to its
+// owner. The error of the transport is not shown since it tells about our network.
+func deliveryError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errForbiddenAddress):
+		return errors.New("the webhook address is not public")
+	case errors.Is(err, errWebhookRedirect):
+		return errors.New("the webhook answered with a redirect")
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("the webhook did not answer in time")
+	default:
+		return errors.New("the webhook could not be reached")
+	}
+}
+
+// webhookPayload is the JSON body sent to the webhooks.
+type webhookPayload struct {
+	Event   string         `json:"event"`
+	Time    time.Time      `json:"time"`
+	Snippet webhookSnippet `json:"snippet"`
+}
+
+// webhookSnippet is the snippet an event is about.
+type webhookSnippet struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	ContentType string    `json:"content_type"`
+	Visibility  string    `json:"visibility"`
+	Hidden      bool      `json:"hidden"`
+	Created     time.Time `json:"created"`
+	Expires     time.Time `json:"expires"`
+}
+
+// notifyWebhooks queues the event on the snippet for each webhook of its owner. The
+// deliveries are sent later by the webhook worker, so that a slow or broken receiver
+// never slows the request down. Failing to queue the event is only logged.
+func (app *application) notifyWebhooks(event string, s *models.Snippet) {
+	if s.UserID == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(&webhookPayload{
+		Event: event,
+		Time:  time.Now().UTC(),
+		Snippet: webhookSnippet{
+			ID:          s.ID,
+			Title:       s.Title,
+			ContentType: s.ContentType,
+			Visibility:  s.Visibility,
+			Hidden:      s.Hidden,
+			Created:     s.Created.UTC(),
+			Expires:     s.Expires.UTC(),
+		},
+	})
+	if err == nil {
+		err = app.webhooks.Enqueue(s.UserID, event, payload)
+	}
+	if err != nil {
+		app.logger.Error("Failed to queue webhook event", "event", event, "snippet_id", s.ID, "error", err.Error())
+	}
+}
+
+// notifySnippet queues the event on the snippet with given id, as it is now in the database.
+func (app *application) notifySnippet(ctx context.Context, event string, id int) {
+	s, err := app.snippets.Find(ctx, id)
+	if err != nil {
+		app.logger.Error("Failed to queue webhook event", "event", event, "snippet_id", id, "error", err.Error())
+		return
+	}
+	app.notifyWebhooks(event, s)
+}
+
+// notifyExpired queues the expiry of the snippets which have expired since the last call.
+func (app *application) notifyExpired(ctx context.Context) error {
+	snippets, err := app.snippets.Expired(ctx, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		app.notifyWebhooks(webhookSnippetExpired, s)
+		if err := app.snippets.MarkExpiryNotified(ctx, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWebhooks notifies the expired snippets and sends the due deliveries every interval
+// until ctx is done.
+func (app *application) runWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			notifyErr := app.notifyExpired(ctx)
+			if notifyErr != nil {
+				app.logger.Error("Failed to notify expired snippets", "error", notifyErr.Error())
+			}
+			deliverErr := app.webhookWorker.DeliverDue(ctx, time.Now())
+			if deliverErr != nil {
+				app.logger.Error("Failed to deliver webhooks", "error", deliverErr.Error())
+			}
+			app.workers.webhooks.ran(errors.Join(notifyErr, deliverErr))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// webhookWorker sends the deliveries of the outbox to the webhooks, retrying the failed
+// ones with exponential backoff.
+type webhookWorker struct {
+	store interface {
+		Due(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+		RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error
+	}
+	client      *http.Client
+	backoff     time.Duration
+	maxAttempts int
+}
+
+// newWebhookWorker return a worker which sends the deliveries of store with client.
+func newWebhookWorker(store interface {
+	Due(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error
+}, client *http.Client) *webhookWorker {
+	return &webhookWorker{
+		store:       store,
+		client:      client,
+		backoff:     webhookBackoff,
+		maxAttempts: webhookMaxAttempts,
+	}
+}
+
+// DeliverDue sends the deliveries which are due at now and records the outcome of each
+// attempt. It stops early if ctx is done.
+func (w *webhookWorker) DeliverDue(ctx context.Context, now time.Time) error {
+	deliveries, err := w.store.Due(now, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		status, next := models.DeliveryDelivered, now
+		errMsg := ""
+		code, err := w.send(ctx, d)
+		if ctx.Err() != nil {
+			// The attempt was cut short by the shutdown, so it does not count.
+			return nil
+		}
+		if err != nil {
+			errMsg = err.Error()
+			status, next = models.DeliveryPending, now.Add(w.retryDelay(d.Attempts+1))
+			if d.Attempts+1 >= w.maxAttempts {
+				status = models.DeliveryFailed
+			}
+		}
+
+		if err := w.store.RecordAttempt(d.ID, status, code, errMsg, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryDelay return how long to wait after the given number of failed attempts.
+func (w *webhookWorker) retryDelay(attempts int) time.Duration {
+	return w.backoff << (attempts - 1)
+}
+
+// send posts the payload of the delivery to its webhook, and return the status code of
+// the response. Any status other than 2xx is an error.
+func (w *webhookWorker) send(ctx context.Context, d *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhook")
+	req.Header.Set("X-Snippetbox-Event", d.Event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.Itoa(d.ID))
+	timestamp := time.Now().Unix()
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, signPayload(d.Secret, timestamp, d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, deliveryError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// signPayload return the signature of the payload sent at timestamp, in Unix seconds,
+// with the secret of a webhook. It is of the form "sha256=<hex HMAC-SHA256>" of
+// "<timestamp>.<payload>". Receivers compute it again to check that a payload comes
+// from us and has not been altered, and check the timestamp to refuse a replay.
+func signPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret return a random secret for a new webhook.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+	"kerseeeHuang.com/snippetbox/pkg/models/mock"
+)
+
+// outboxStore holds deliveries in memory and records the attempts on them.
+type outboxStore struct {
+	deliveries []*models.WebhookDelivery
+}
+
+func (s *outboxStore) Due(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	due := []*models.WebhookDelivery{}
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttempt.After(now) && len(due) < limit {
+			// Return a copy like a database would.
+			c := *d
+			due = append(due, &c)
+		}
+	}
+	return due, nil
+}
+
+func (s *outboxStore) RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error {
+	for _, d := range s.deliveries {
+		if d.ID == id {
+			d.Status, d.ResponseCode, d.Error, d.NextAttempt = status, responseCode, errMsg, next
+			d.Attempts++
+		}
+	}
+	return nil
+}
+
+// newReceiver starts a webhook receiver which answers with the given status codes in
+// turn, repeating the last one. It fails the test if a payload is not correctly signed.
+func newReceiver(t *testing.T, secret string, codes ...int) (*httptest.Server, *int) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("want a timestamp: %v", err)
+		}
+		if age := time.Since(time.Unix(timestamp, 0)); age < -time.Minute || age > time.Minute {
+			t.Errorf("want the timestamp of the delivery; got %d", timestamp)
+		}
+		if got, want := r.Header.Get(webhookSignatureHeader), signPayload(secret, timestamp, body); got != want {
+			t.Errorf("want signature %q; got %q", want, got)
+		}
+		if got := r.Header.Get("X-Snippetbox-Event"); got != webhookSnippetCreated {
+			t.Errorf("want event %q; got %q", webhookSnippetCreated, got)
+		}
+
+		code := codes[len(codes)-1]
+		if hits < len(codes) {
+			code = codes[hits]
+		}
+		hits++
+		w.WriteHeader(code)
+	}))
+	return srv, &hits
+}
+
+func TestWebhookWorkerRetries(t *testing.T) {
+	srv, hits := newReceiver(t, "s3cret", http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent)
+	defer srv.Close()
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := &models.WebhookDelivery{
+		ID:          1,
+		URL:         srv.URL,
+		Secret:      "s3cret",
+		Event:       webhookSnippetCreated,
+		Payload:     []byte(`{"event":"snippet.created"}`),
+		Status:      models.DeliveryPending,
+		NextAttempt: now,
+	}
+	store := &outboxStore{deliveries: []*models.WebhookDelivery{d}}
+	w := newWebhookWorker(store, srv.Client())
+
+	tests := []struct {
+		name         string
+		at           time.Time
+		wantHits     int
+		wantStatus   string
+		wantCode     int
+		wantNext     time.Time
+		wantAttempts int
+	}{
+		{"First attempt fails", now, 1, models.DeliveryPending, 500, now.Add(time.Minute), 1},
+		{"Not due yet", now.Add(30 * time.Second), 1, models.DeliveryPending, 500, now.Add(time.Minute), 1},
+		{"Second attempt fails", now.Add(time.Minute), 2, models.DeliveryPending, 502, now.Add(3 * time.Minute), 2},
+		{"Third attempt succeeds", now.Add(3 * time.Minute), 3, models.DeliveryDelivered, 204, now.Add(3 * time.Minute), 3},
+		{"Delivered once", now.Add(time.Hour), 3, models.DeliveryDelivered, 204, now.Add(3 * time.Minute), 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := w.DeliverDue(context.Background(), test.at); err != nil {
+				t.Fatal(err)
+			}
+			if *hits != test.wantHits {
+				t.Errorf("want %d requests; got %d", test.wantHits, *hits)
+			}
+			if d.Status != test.wantStatus {
+				t.Errorf("want status %q; got %q", test.wantStatus, d.Status)
+			}
+			if d.ResponseCode != test.wantCode {
+				t.Errorf("want response code %d; got %d", test.wantCode, d.ResponseCode)
+			}
+			if !d.NextAttempt.Equal(test.wantNext) {
+				t.Errorf("want next attempt at %v; got %v", test.wantNext, d.NextAttempt)
+			}
+			if d.Attempts != test.wantAttempts {
+				t.Errorf("want %d attempts; got %d", test.wantAttempts, d.Attempts)
+			}
+		})
+	}
+}
+
+func TestWebhookWorkerGivesUp(t *testing.T) {
+	srv, hits := newReceiver(t, "s3cret", http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := &models.WebhookDelivery{
+		ID:          1,
+		URL:         srv.URL,
+		Secret:      "s3cret",
+		Event:       webhookSnippetCreated,
+		Payload:     []byte(`{}`),
+		Status:      models.DeliveryPending,
+		NextAttempt: now,
+	}
+	store := &outboxStore{deliveries: []*models.WebhookDelivery{d}}
+	w := newWebhookWorker(store, srv.Client())
+	w.maxAttempts = 3
+
+	// Run long enough for all the retries to be due.
+	for i := 0; i < 5; i++ {
+		if err := w.DeliverDue(context.Background(), now.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if *hits != 3 {
+		t.Errorf("want %d requests; got %d", 3, *hits)
+	}
+	if d.Status != models.DeliveryFailed {
+		t.Errorf("want status %q; got %q", models.DeliveryFailed, d.Status)
+	}
+	if d.Error == "" {
+		t.Error("want the error of the last attempt")
+	}
+}
+
+func TestWebhookWorkerUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := &models.WebhookDelivery{ID: 1, URL: url, Payload: []byte(`{}`), Status: models.DeliveryPending, NextAttempt: now}
+	store := &outboxStore{deliveries: []*models.WebhookDelivery{d}}
+
+	if err := newWebhookWorker(store, http.DefaultClient).DeliverDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != models.DeliveryPending || d.Attempts != 1 || d.ResponseCode != 0 || d.Error != "the webhook could not be reached" {
+		t.Errorf("want a failed attempt to retry; got %+v", d)
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	srv, hits := newReceiver(t, "s3cret", http.StatusNoContent)
+	defer srv.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL, http.StatusFound))
+	defer redirect.Close()
+
+	// The test servers listen on the loopback, so a client dialing them directly is used
+	// to check the redirects.
+	direct := newWebhookClient()
+	direct.Transport = srv.Client().Transport
+
+	tests := []struct {
+		name      string
+		client    *http.Client
+		url       string
+		wantError string
+	}{
+		{"Loopback", newWebhookClient(), srv.URL, "the webhook address is not public"},
+		{"Localhost", newWebhookClient(), "http://localhost:1/", "the webhook address is not public"},
+		{"Metadata", newWebhookClient(), "http://169.254.169.254/latest/meta-data/", "the webhook address is not public"},
+		{"Private", newWebhookClient(), "http://10.0.0.1/", "the webhook address is not public"},
+		{"Redirect", direct, redirect.URL, "the webhook answered with a redirect"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			d := &models.WebhookDelivery{ID: 1, URL: test.url, Secret: "s3cret", Event: webhookSnippetCreated, Payload: []byte(`{}`), Status: models.DeliveryPending, NextAttempt: now}
+			store := &outboxStore{deliveries: []*models.WebhookDelivery{d}}
+
+			if err := newWebhookWorker(store, test.client).DeliverDue(context.Background(), now); err != nil {
+				t.Fatal(err)
+			}
+			if d.Error != test.wantError {
+				t.Errorf("want error %q; got %q", test.wantError, d.Error)
+			}
+		})
+	}
+	if *hits != 0 {
+		t.Errorf("want no request to the receiver; got %d", *hits)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b::a01:203", false},
+		{"64:ff9b::5db8:d822", true},
+		{"64:ff9b:1::1", false},
+		{"2001:db8::1", false},
+		{"2002:7f00:1::1", false},
+		{"fe80::1%eth0", false},
+		{"ff02::1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			if got := isPublicIP(netip.MustParseAddr(test.ip)); got != test.want {
+				t.Errorf("want %t; got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	got := signPayload("key", 1646136000, []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f8b7ddbec54bc2cf6296537a27e9d215f91b5eee7568b1dc1da49034c7bda2e1"
+	if got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+// webhookRecorder records the events queued to it.
+type webhookRecorder struct {
+	mock.WebhookModel
+	payloads []*webhookPayload
+}
+
+func (m *webhookRecorder) Enqueue(userID int, event string, payload []byte) error {
+	p := &webhookPayload{}
+	if err := json.Unmarshal(payload, p); err != nil {
+		return err
+	}
+	m.payloads = append(m.payloads, p)
+	return nil
+}
+
+func TestSnippetEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantEvent string
+	}{
+		{"Hide", "/admin/snippets/1/hide", webhookSnippetUpdated},
+		{"Unhide", "/admin/snippets/1/unhide", webhookSnippetUpdated},
+		{"Delete", "/admin/snippets/1/delete", webhookSnippetDeleted},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			recorder := &webhookRecorder{}
+			app.webhooks = recorder
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			ts.login(t, "carol@example.com")
+			_, _, body := ts.get(t, "/admin/snippets")
+
+			ts.postForm(t, test.urlPath, url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+
+			if len(recorder.payloads) != 1 {
+				t.Fatalf("want 1 event; got %d", len(recorder.payloads))
+			}
+			p := recorder.payloads[0]
+			if p.Event != test.wantEvent || p.Snippet.ID != 1 || p.Snippet.Title != "An old silent pond" {
+				t.Errorf("want %s of snippet 1; got %+v", test.wantEvent, p)
+			}
+		})
+	}
+}
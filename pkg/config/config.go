@@ -0,0 +1,547 @@
+// Package config loads the configuration of the web server. Each setting is read, in
+// increasing order of precedence, from its default, a config file, an environment
+// variable and a command-line flag.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables of the settings.
+const EnvPrefix = "SNIPPETBOX_"
+
+// DefaultSecret is the session secret used when none is given. It is public, so it is
+// only accepted in debug mode.
+const DefaultSecret = "s6Ndh+pPbnzHbS*+9Pk8qGwhTzbpa@ge"
+
+// DefaultDSN is the data source name used when none is given. It has no password, so
+// it is only accepted in debug mode, for a local database.
+const DefaultDSN = "web@/snippetbox?parseTime=true"
+
+// redacted replaces the secrets in the printed config.
+const redacted = "[redacted]"
+
+// fileSuffix is appended to the key of a secret setting to give instead the path of a
+// file holding it, such as a Docker or Kubernetes secret.
+const fileSuffix = "_file"
+
+// Config holds the settings of the web server. The config tag is the key of a setting
+// in config files; its flag is the key with hyphens and its environment variable is
+// the upper-cased key with EnvPrefix. Lists are separated by commas in flags and
+// environment variables, and by lines in secret files.
+type Config struct {
+	Addr   string `config:"addr" usage:"HTTP network address"`
+	DSN    string `config:"dsn" usage:"MySQL data source name" secret:"dsn"`
+	Secret string `config:"secret" usage:"Secret key of the session cookies, 32 bytes long" secret:"true"`
+	// OldSecrets are the previous session secrets, which are still accepted in cookies
+	// but no longer used to write them, so that the secret can be rotated without
+	// logging the users out.
+	OldSecrets     []string `config:"old_secrets" usage:"Previous secret keys still accepted in session cookies" secret:"true"`
+	Debug          bool     `config:"debug" usage:"Set true for debug mode"`
+	FrameAncestors string   `config:"frame_ancestors" usage:"Space-separated CSP sources allowed to embed snippets, none by default"`
+	// BaseURL is the public URL of the site, which the absolute links of the feeds,
+	// embeds and JSON responses start with, rather than the Host header of the client.
+	BaseURL      string        `config:"base_url" usage:"Public URL of the site, used in absolute links"`
+	DrainTimeout time.Duration `config:"drain_timeout" usage:"Time to drain the requests in flight on shutdown"`
+	// DrainDelay is how long the server keeps accepting requests on shutdown after
+	// /readyz fails, so that the load balancers stop sending requests first.
+	DrainDelay time.Duration `config:"drain_delay" usage:"Time to keep accepting requests on shutdown after /readyz fails"`
+	// QueryTimeout bounds the database queries of a model call, so that a slow query
+	// does not outlive the request it serves.
+	QueryTimeout time.Duration `config:"query_timeout" usage:"Maximum time of the database queries of a model call"`
+	// MetricsAllow are the IP addresses and CIDR networks of the clients allowed to
+	// read /metrics. The metrics are hidden if it is empty, which is the default. The
+	// client is the peer of the connection, so behind a reverse proxy the proxy is the
+	// client, and allowing the loopback would show the metrics to everyone.
+	MetricsAllow []string `config:"metrics_allow" usage:"IP addresses and CIDR networks allowed to read /metrics"`
+	LogLevel     string   `config:"log_level" usage:"Minimum level of the logs: debug, info, warn or error"`
+	LogFormat    string   `config:"log_format" usage:"Format of the logs: json or text"`
+	// TraceExporter is where the spans are sent: nowhere, to the OTLP collector at
+	// TraceEndpoint, to the standard output or to TraceFile.
+	TraceExporter    string  `config:"trace_exporter" usage:"Exporter of the traces: none, otlp, stdout or file"`
+	TraceEndpoint    string  `config:"trace_endpoint" usage:"URL of the OTLP/HTTP collector of the traces"`
+	TraceFile        string  `config:"trace_file" usage:"Path of the file the traces are appended to"`
+	TraceSampleRatio float64 `config:"trace_sample_ratio" usage:"Fraction of the new traces which are recorded, from 0 to 1"`
+}
+
+// Default return the config used when no setting is given.
+func Default() *Config {
+	return &Config{
+		Addr:           ":4000",
+		DSN:            DefaultDSN,
+		Secret:         DefaultSecret,
+		FrameAncestors: "'none'",
+		BaseURL:        "https://localhost:4000",
+		DrainTimeout:   15 * time.Second,
+		QueryTimeout:   5 * time.Second,
+		LogLevel:       "info",
+		LogFormat:      "json",
+
+		TraceExporter:    "none",
+		TraceEndpoint:    "http://localhost:4318",
+		TraceSampleRatio: 1,
+	}
+}
+
+// Load return the config given by the defaults, the config file, the environment and
+// the command-line arguments, in this order. The config file is named by the -config
+// flag or the SNIPPETBOX_CONFIG variable, and its format is chosen by its extension:
+// .json, .yaml, .yml or .toml. The result is validated.
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	// Parse the flags first, to find the config file, but only apply them last.
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "Path of the config file (JSON, YAML or TOML)")
+	set := map[string]interface{}{}
+	for _, s := range c.settings() {
+		for _, key := range s.keys() {
+			fs.Var(&flagValue{setting: s, key: key, set: set}, flagName(key), s.flagUsage(key))
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("config: unexpected argument %q", fs.Arg(0))
+	}
+
+	if path := *configFile; path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(values, func(key string) string { return path + ": " + key }); err != nil {
+			return nil, err
+		}
+	}
+
+	env := map[string]interface{}{}
+	for _, s := range c.settings() {
+		for _, key := range s.keys() {
+			if v := getenv(envName(key)); v != "" {
+				env[key] = v
+			}
+		}
+	}
+	if err := c.apply(env, envName); err != nil {
+		return nil, err
+	}
+
+	if err := c.apply(set, func(key string) string { return "-" + flagName(key) }); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that the settings can be used to run the server.
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Addr == "" {
+		problems = append(problems, "addr is empty")
+	}
+	if c.DSN == "" {
+		problems = append(problems, "dsn is empty")
+	}
+	if c.DSN == DefaultDSN && !c.Debug {
+		problems = append(problems, "dsn is the built-in default, which is only allowed in debug mode")
+	}
+	if len(c.Secret) != 32 {
+		problems = append(problems, fmt.Sprintf("secret must be 32 bytes long, not %d", len(c.Secret)))
+	}
+	if c.Secret == DefaultSecret && !c.Debug {
+		problems = append(problems, "secret is the built-in default, which is only allowed in debug mode")
+	}
+	for i, secret := range c.OldSecrets {
+		if len(secret) != 32 {
+			problems = append(problems, fmt.Sprintf("old secret %d must be 32 bytes long, not %d", i+1, len(secret)))
+		}
+		if secret == DefaultSecret && !c.Debug {
+			problems = append(problems, fmt.Sprintf("old secret %d is the built-in default, which is only allowed in debug mode", i+1))
+		}
+	}
+	if c.DrainTimeout <= 0 {
+		problems = append(problems, "drain_timeout must be positive")
+	}
+	if c.DrainDelay < 0 {
+		problems = append(problems, "drain_delay must not be negative")
+	}
+	if c.QueryTimeout <= 0 {
+		problems = append(problems, "query_timeout must be positive")
+	}
+	if _, err := c.ParseBaseURL(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := c.MetricsNetworks(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		problems = append(problems, fmt.Sprintf("log_level: unknown level %q", c.LogLevel))
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		problems = append(problems, fmt.Sprintf("log_format: unknown format %q", c.LogFormat))
+	}
+	switch c.TraceExporter {
+	case "none", "stdout":
+	case "otlp":
+		if u, err := url.Parse(c.TraceEndpoint); err != nil || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("trace_endpoint: invalid URL %q", c.TraceEndpoint))
+		}
+	case "file":
+		if c.TraceFile == "" {
+			problems = append(problems, "trace_file is empty, but the file exporter needs it")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("trace_exporter: unknown exporter %q", c.TraceExporter))
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		problems = append(problems, "trace_sample_ratio must be between 0 and 1")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("config: invalid settings: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ParseBaseURL return BaseURL, which must be an absolute http or https URL without
+// query or fragment.
+func (c *Config) ParseBaseURL() (*url.URL, error) {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("base_url: invalid URL %q", c.BaseURL)
+	}
+	return u, nil
+}
+
+// MetricsNetworks return the networks of MetricsAllow. A single address is a network
+// of one address.
+func (c *Config) MetricsNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(c.MetricsAllow))
+	for _, s := range c.MetricsAllow {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("metrics_allow: invalid address or network %q", s)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// LogValue return the settings as a group of log fields, with the secrets redacted.
+func (c *Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.key, s.redacted()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// String return the settings as "key = value" lines, with the secrets redacted, so
+// that the effective config can be logged.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, s := range c.settings() {
+		fmt.Fprintf(&b, "%s = %s\n", s.key, s.redacted())
+	}
+	return b.String()
+}
+
+// readFile return the settings in the config file at path.
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("config: unsupported config file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// apply sets the settings given by one source, by key. The secret settings can also be
+// given by the path of a file holding them, under their key with fileSuffix, but not
+// both ways in the same source. name return the name of a key in the errors.
+func (c *Config) apply(values map[string]interface{}, name func(key string) string) error {
+	settings := map[string]*setting{}
+	for _, s := range c.settings() {
+		for _, key := range s.keys() {
+			settings[key] = s
+		}
+	}
+
+	for key, v := range values {
+		s, ok := settings[key]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown setting", name(key))
+		}
+
+		var err error
+		if key == s.key {
+			err = s.setValue(v)
+		} else if _, ok := values[s.key]; ok {
+			err = fmt.Errorf("cannot be set with %s", name(s.key))
+		} else if path, ok := v.(string); !ok {
+			err = errors.New("must be a path")
+		} else {
+			err = s.setFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", name(key), err)
+		}
+	}
+	return nil
+}
+
+// setting is a field of a Config.
+type setting struct {
+	key    string
+	usage  string
+	secret string
+	value  reflect.Value
+}
+
+// settings return the settings of c, which set the fields of c.
+func (c *Config) settings() []*setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	settings := make([]*setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		settings = append(settings, &setting{
+			key:    f.Tag.Get("config"),
+			usage:  f.Tag.Get("usage"),
+			secret: f.Tag.Get("secret"),
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// keys return the keys of the setting: its own, and the one of its file if it is secret.
+func (s *setting) keys() []string {
+	if s.secret == "" {
+		return []string{s.key}
+	}
+	return []string{s.key, s.key + fileSuffix}
+}
+
+// flagUsage return the usage of the flag of the given key of the setting.
+func (s *setting) flagUsage(key string) string {
+	if key != s.key {
+		return "Path of a file holding -" + flagName(s.key)
+	}
+	return s.usage
+}
+
+// flagName return the name of the command-line flag of a key.
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName return the name of the environment variable of a key.
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// setValue sets the setting to a value of a config file, which is a list or a scalar.
+func (s *setting) setValue(v interface{}) error {
+	switch v := v.(type) {
+	case []interface{}:
+		if s.value.Kind() != reflect.Slice {
+			return errors.New("must be a single value")
+		}
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}, nil:
+				return errors.New("must be a list of values")
+			}
+			list = append(list, fmt.Sprint(item))
+		}
+		s.value.Set(reflect.ValueOf(list))
+		return nil
+	case map[string]interface{}, nil:
+		return errors.New("must be a single value")
+	}
+	return s.set(fmt.Sprint(v))
+}
+
+// setFile sets the setting to the content of the file at path, without its final line
+// break. Lists have one item per line.
+func (s *setting) setFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if s.value.Kind() == reflect.Slice {
+		s.value.Set(reflect.ValueOf(splitList(string(data), "\n")))
+		return nil
+	}
+	return s.set(strings.TrimRight(string(data), "\r\n"))
+}
+
+// splitList return the items of a list separated by sep, without blanks.
+func splitList(v, sep string) []string {
+	var list []string
+	for _, item := range strings.Split(v, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// set parses v into the field of the setting.
+func (s *setting) set(v string) error {
+	switch p := s.value.Addr().Interface().(type) {
+	case *string:
+		*p = v
+	case *[]string:
+		*p = splitList(v, ",")
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*p = d
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", p))
+	}
+	return nil
+}
+
+// String return the value of the setting.
+func (s *setting) String() string {
+	if list, ok := s.value.Interface().([]string); ok {
+		return strings.Join(list, ", ")
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// redacted return the value of the setting with its secret parts hidden.
+func (s *setting) redacted() string {
+	switch s.secret {
+	case "true":
+		if list, ok := s.value.Interface().([]string); ok {
+			hidden := make([]string, len(list))
+			for i := range hidden {
+				hidden[i] = redacted
+			}
+			return strings.Join(hidden, ", ")
+		}
+		if s.String() == "" {
+			return ""
+		}
+		return redacted
+	case "dsn":
+		return redactDSN(s.String())
+	}
+	return s.String()
+}
+
+// redactDSN hides the password of a MySQL data source name, of the form
+// "user:password@protocol(address)/dbname?params".
+func redactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + redacted + dsn[at:]
+}
+
+// flagValue is the flag.Value of a key of a setting. It only records the flags which
+// are given, so that they can be applied after the other sources.
+type flagValue struct {
+	*setting
+	key string
+	set map[string]interface{}
+}
+
+// Set records the value of the flag once it is checked.
+func (f *flagValue) Set(v string) error {
+	if f.key == f.setting.key {
+		// Check the value on a copy of the setting, to report errors while parsing flags.
+		check := *f.setting
+		check.value = reflect.New(f.value.Type()).Elem()
+		if err := check.set(v); err != nil {
+			return err
+		}
+	}
+	f.set[f.key] = v
+	return nil
+}
+
+// String return the default of the flag, shown in the usage.
+func (f *flagValue) String() string {
+	if f.setting == nil || f.key != f.setting.key {
+		return ""
+	}
+	return f.redacted()
+}
+
+// IsBoolFlag lets boolean settings be given as -name without a value.
+func (f *flagValue) IsBoolFlag() bool {
+	return f.key == f.setting.key && f.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSecret is a valid secret which is not the default.
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// writeFile writes a config file with given name and content in a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// env return a getenv func of the given variables.
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, "snippetbox.json", `{"addr": ":5000", "dsn": "file:pw@/file", "secret": "`+testSecret+`", "drain_timeout": "20s"}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want Config
+	}{
+		{
+			"Defaults in debug mode",
+			[]string{"-debug"},
+			nil,
+			Config{Addr: ":4000", DSN: Default().DSN, Secret: DefaultSecret, Debug: true, FrameAncestors: "'none'", BaseURL: "https://localhost:4000", DrainTimeout: 15 * time.Second, QueryTimeout: 5 * time.Second, LogLevel: "info", LogFormat: "json", TraceExporter: "none", TraceEndpoint: "http://localhost:4318", TraceSampleRatio: 1},
+		},
+		{
+			"File",
+			[]string{"-config", path},
+			nil,
+			Config{Addr: ":5000", DSN: "file:pw@/file", Secret: testSecret, FrameAncestors: "'none'", BaseURL: "https://localhost:4000", DrainTimeout: 20 * time.Second, QueryTimeout: 5 * time.Second, LogLevel: "info", LogFormat: "json", TraceExporter: "none", TraceEndpoint: "http://localhost:4318", TraceSampleRatio: 1},
+		},
+		{
+			"Environment over file",
+			nil,
+			map[string]string{"SNIPPETBOX_CONFIG": path, "SNIPPETBOX_ADDR": ":6000", "SNIPPETBOX_FRAME_ANCESTORS": "'self'"},
+			Config{Addr: ":6000", DSN: "file:pw@/file", Secret: testSecret, FrameAncestors: "'self'", BaseURL: "https://localhost:4000", DrainTimeout: 20 * time.Second, QueryTimeout: 5 * time.Second, LogLevel: "info", LogFormat: "json", TraceExporter: "none", TraceEndpoint: "http://localhost:4318", TraceSampleRatio: 1},
+		},
+		{
+			"Flags over environment",
+			[]string{"-config", path, "-addr", ":7000", "-drain-timeout", "1m", "-query-timeout", "2s", "-metrics-allow", "10.0.0.0/8, 192.168.1.1", "-trace-sample-ratio", "0.25"},
+			map[string]string{"SNIPPETBOX_ADDR": ":6000", "SNIPPETBOX_DRAIN_TIMEOUT": "5s", "SNIPPETBOX_TRACE_EXPORTER": "otlp"},
+			Config{Addr: ":7000", DSN: "file:pw@/file", Secret: testSecret, FrameAncestors: "'none'", BaseURL: "https://localhost:4000", DrainTimeout: time.Minute, QueryTimeout: 2 * time.Second, MetricsAllow: []string{"10.0.0.0/8", "192.168.1.1"}, LogLevel: "info", LogFormat: "json", TraceExporter: "otlp", TraceEndpoint: "http://localhost:4318", TraceSampleRatio: 0.25},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := Load("snippetbox", test.args, env(test.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*c, test.want) {
+				t.Errorf("want %+v; got %+v", test.want, *c)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"JSON", "snippetbox.json", `{"addr": ":5000", "debug": true, "drain_timeout": "3s", "trace_sample_ratio": 0.5}`},
+		{"YAML", "snippetbox.yaml", "# Local config\naddr: \":5000\"\ndebug: true\ndrain_timeout: 3s\ntrace_sample_ratio: 0.5\n"},
+		{"TOML", "snippetbox.toml", "# Local config\naddr = \":5000\" # HTTPS\ndebug = true\ndrain_timeout = '3s'\ntrace_sample_ratio = 0.5\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, test.file, test.content)
+			c, err := Load("snippetbox", []string{"-config", path}, env(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Addr != ":5000" || !c.Debug || c.DrainTimeout != 3*time.Second || c.TraceSampleRatio != 0.5 {
+				t.Errorf("want the settings of the file; got %+v", *c)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Built-in secret", nil, nil, "secret is the built-in default"},
+		{"Built-in DSN", []string{"-secret", testSecret}, nil, "dsn is the built-in default"},
+		{"Short secret", []string{"-secret", "short"}, nil, "32 bytes long"},
+		{"Empty addr", []string{"-secret", testSecret, "-addr", ""}, nil, "addr is empty"},
+		{"Invalid flag", []string{"-drain-timeout", "soon"}, nil, `invalid duration "soon"`},
+		{"Invalid environment", nil, map[string]string{"SNIPPETBOX_DEBUG": "maybe"}, "SNIPPETBOX_DEBUG"},
+		{"Missing file", []string{"-config", "missing.json"}, nil, "missing.json"},
+		{"Argument", []string{"-debug", "serve"}, nil, "unexpected argument"},
+		{"Negative drain delay", []string{"-debug", "-drain-delay", "-1s"}, nil, "drain_delay must not be negative"},
+		{"Invalid query timeout", []string{"-debug", "-query-timeout", "0s"}, nil, "query_timeout must be positive"},
+		{"Invalid log level", []string{"-debug", "-log-level", "verbose"}, nil, `unknown level "verbose"`},
+		{"Invalid log format", []string{"-debug", "-log-format", "xml"}, nil, `unknown format "xml"`},
+		{"Invalid metrics network", []string{"-debug", "-metrics-allow", "10.0.0.0/33"}, nil, `invalid address or network "10.0.0.0/33"`},
+		{"Invalid base URL", []string{"-debug", "-base-url", "localhost:4000"}, nil, `base_url: invalid URL "localhost:4000"`},
+		{"Base URL with query", []string{"-debug", "-base-url", "https://example.com/?a=b"}, nil, "base_url: invalid URL"},
+		{"Invalid trace exporter", []string{"-debug", "-trace-exporter", "jaeger"}, nil, `unknown exporter "jaeger"`},
+		{"Invalid trace endpoint", []string{"-debug", "-trace-exporter", "otlp", "-trace-endpoint", "collector"}, nil, `invalid URL "collector"`},
+		{"Missing trace file", []string{"-debug", "-trace-exporter", "file"}, nil, "trace_file is empty"},
+		{"Invalid trace sample ratio", []string{"-debug", "-trace-sample-ratio", "2"}, nil, "between 0 and 1"},
+		{"Invalid number", []string{"-debug", "-trace-sample-ratio", "half"}, nil, `invalid number "half"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load("snippetbox", test.args, env(test.env))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing %q; got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"Unknown format", "snippetbox.ini", "addr=:5000\n", "unsupported config file format"},
+		{"Unknown setting", "snippetbox.json", `{"port": 4000}`, "port: unknown setting"},
+		{"Nested value", "snippetbox.yaml", "addr:\n  host: localhost\n", "must be a single value"},
+		{"Invalid value", "snippetbox.yaml", "debug: sometimes\n", "invalid boolean"},
+		{"TOML table", "snippetbox.toml", "[server]\naddr = \":5000\"\n", "server: unknown setting"},
+		{"TOML duplicate", "snippetbox.toml", "addr = \":5000\"\naddr = \":6000\"\n", `Key 'addr' has already been defined`},
+		{"Invalid TOML", "snippetbox.toml", "addr = :5000\n", "line 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, test.file, test.content)
+			_, err := Load("snippetbox", []string{"-debug", "-config", path}, env(nil))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing %q; got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	secretFile := writeFile(t, "secret", testSecret+"\n")
+	oldSecrets := []string{"abcdef0123456789abcdef0123456789", "9876543210fedcba9876543210fedcba"}
+	oldSecretsFile := writeFile(t, "old_secrets", oldSecrets[0]+"\n"+oldSecrets[1]+"\n\n")
+	dsnFile := writeFile(t, "dsn", "web:from-file@/snippetbox\r\n")
+
+	tests := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		wantSecret     string
+		wantOldSecrets []string
+	}{
+		{"Flag", []string{"-secret-file", secretFile}, nil, testSecret, nil},
+		{"Environment", nil, map[string]string{"SNIPPETBOX_SECRET_FILE": secretFile, "SNIPPETBOX_OLD_SECRETS_FILE": oldSecretsFile}, testSecret, oldSecrets},
+		{"Flag over environment", []string{"-secret", oldSecrets[0]}, map[string]string{"SNIPPETBOX_SECRET_FILE": secretFile}, oldSecrets[0], nil},
+		{"List in flag", []string{"-secret-file", secretFile, "-old-secrets", oldSecrets[0] + ", " + oldSecrets[1]}, nil, testSecret, oldSecrets},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars := map[string]string{"SNIPPETBOX_DSN_FILE": dsnFile}
+			for key, v := range test.env {
+				vars[key] = v
+			}
+			c, err := Load("snippetbox", test.args, env(vars))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Secret != test.wantSecret {
+				t.Errorf("want secret %q; got %q", test.wantSecret, c.Secret)
+			}
+			if !reflect.DeepEqual(c.OldSecrets, test.wantOldSecrets) {
+				t.Errorf("want old secrets %q; got %q", test.wantOldSecrets, c.OldSecrets)
+			}
+			if c.DSN != "web:from-file@/snippetbox" {
+				t.Errorf("want the DSN of the file; got %q", c.DSN)
+			}
+		})
+	}
+}
+
+func TestLoadSecretFileErrors(t *testing.T) {
+	secretFile := writeFile(t, "secret", testSecret)
+	listFile := writeFile(t, "snippetbox.yaml", "secret: "+testSecret+"\nold_secrets:\n  - "+DefaultSecret+"\n")
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"Both ways", []string{"-secret", testSecret, "-secret-file", secretFile}, nil, "-secret-file: cannot be set with -secret"},
+		{"Missing file", nil, map[string]string{"SNIPPETBOX_SECRET_FILE": "missing"}, "SNIPPETBOX_SECRET_FILE: open missing"},
+		{"Not secret", []string{"-addr-file", secretFile}, nil, "flag provided but not defined"},
+		{"Short old secret", []string{"-secret-file", secretFile, "-old-secrets", "short"}, nil, "old secret 1 must be 32 bytes long"},
+		{"Built-in old secret", []string{"-config", listFile}, nil, "old secret 1 is the built-in default"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load("snippetbox", test.args, env(test.env))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing %q; got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	c := Default()
+	c.DSN = "web:hunter2@tcp(db:3306)/snippetbox?parseTime=true"
+	c.OldSecrets = []string{testSecret, testSecret}
+
+	got := c.String()
+	for _, want := range []string{
+		"addr = :4000\n",
+		"dsn = web:[redacted]@tcp(db:3306)/snippetbox?parseTime=true\n",
+		"secret = [redacted]\n",
+		"old_secrets = [redacted], [redacted]\n",
+		"drain_timeout = 15s\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in %q", want, got)
+		}
+	}
+	for _, secret := range []string{"hunter2", DefaultSecret, testSecret} {
+		if strings.Contains(got, secret) {
+			t.Errorf("want %q redacted in %q", secret, got)
+		}
+	}
+}
+
+func TestMetricsNetworks(t *testing.T) {
+	c := Default()
+	c.MetricsAllow = []string{"10.0.0.0/8", "192.168.1.1", "::1"}
+
+	networks, err := c.MetricsNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"::1", true},
+		{"::2", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			got := false
+			for _, network := range networks {
+				got = got || network.Contains(net.ParseIP(test.ip))
+			}
+			if got != test.want {
+				t.Errorf("want %t; got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	c := Default()
+	c.DSN = "web:hunter2@/snippetbox"
+
+	var b strings.Builder
+	slog.New(slog.NewJSONHandler(&b, nil)).Info("Loaded configuration", "config", c)
+
+	got := b.String()
+	for _, want := range []string{`"addr":":4000"`, `"dsn":"web:[redacted]@/snippetbox"`, `"secret":"[redacted]"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %s in %s", want, got)
+		}
+	}
+	if strings.Contains(got, "hunter2") || strings.Contains(got, DefaultSecret) {
+		t.Errorf("want the secrets redacted in %s", got)
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"Password", "web:pass@/snippetbox", "web:[redacted]@/snippetbox"},
+		{"Password with @", "web:p@ss@tcp(db)/snippetbox", "web:[redacted]@tcp(db)/snippetbox"},
+		{"No password", "web@/snippetbox", "web@/snippetbox"},
+		{"No user", "/snippetbox", "/snippetbox"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := redactDSN(test.dsn); got != test.want {
+				t.Errorf("want %q; got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Package feed writes lists of entries as Atom 1.0 and RSS 2.0 feeds.
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Feed is a list of entries, the latest first, which can be written as Atom or RSS.
+type Feed struct {
+	// ID is a permanent and universally unique identifier of the feed, such as a tag URI.
+	ID          string
+	Title       string
+	Description string
+	// Link is the URL of the HTML page of the feed.
+	Link string
+	// Self is the URL of the feed itself.
+	Self   string
+	Author string
+	// Modified is the last time the feed changed apart from the updates of its entries,
+	// such as when an entry was removed. It may be zero.
+	Modified time.Time
+	Entries  []*Entry
+}
+
+// Entry is an item in a feed.
+type Entry struct {
+	// ID is a permanent and universally unique identifier of the entry, such as a tag URI.
+	ID        string
+	Title     string
+	Link      string
+	Content   string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+// Updated return the time the feed was last updated, which is the latest of Modified
+// and the updates of its entries, or the current time if they are all unknown.
+func (f *Feed) Updated() time.Time {
+	updated := f.Modified
+	for _, e := range f.Entries {
+		if e.Updated.After(updated) {
+			updated = e.Updated
+		}
+	}
+	if updated.IsZero() {
+		return time.Now()
+	}
+	return updated
+}
+
+// atomFeed is the XML representation of an Atom feed.
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Links   []atomLink   `xml:"link"`
+	Author  *atomAuthor  `xml:"author,omitempty"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Content   atomContent `xml:"content"`
+}
+
+// Atom return the feed as an Atom 1.0 document.
+func (f *Feed) Atom() ([]byte, error) {
+	feed := &atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+		},
+	}
+	if f.Author != "" {
+		feed.Author = &atomAuthor{Name: f.Author}
+	}
+
+	for _, e := range f.Entries {
+		entry := &atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Content:   atomContent{Type: "text", Body: e.Content},
+		}
+		if e.Author != "" {
+			entry.Author = &atomAuthor{Name: e.Author}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return marshal(feed)
+}
+
+// rssFeed is the XML representation of an RSS feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	Self          atomLink   `xml:"atom:link"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+// RSS return the feed as an RSS 2.0 document.
+func (f *Feed) RSS() ([]byte, error) {
+	feed := &rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+			LastBuildDate: f.Updated().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range f.Entries {
+		feed.Channel.Items = append(feed.Channel.Items, &rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Content,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return marshal(feed)
+}
+
+// marshal encodes v as an indented XML document with its header.
+func marshal(v interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
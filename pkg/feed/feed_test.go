@@ -0,0 +1,104 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	return &Feed{
+		ID:          "tag:example.com,2022:snippets",
+		Title:       "Latest Snippets",
+		Description: "The latest snippets",
+		Link:        "https://example.com/",
+		Self:        "https://example.com/feed.atom",
+		Entries: []*Entry{
+			{
+				ID:        "tag:example.com,2022:snippet/2",
+				Title:     "Second",
+				Link:      "https://example.com/snippet/2",
+				Content:   "if a < b && c > d {}",
+				Published: time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC),
+				Updated:   time.Date(2022, 3, 2, 10, 0, 0, 0, time.UTC),
+			},
+			{
+				ID:        "tag:example.com,2022:snippet/1",
+				Title:     "First",
+				Link:      "https://example.com/snippet/1",
+				Content:   "fmt.Println()",
+				Author:    "Alice",
+				Published: time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+				Updated:   time.Date(2022, 3, 3, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	b, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<id>tag:example.com,2022:snippets</id>`,
+		`<updated>2022-03-03T10:00:00Z</updated>`,
+		`<link rel="self" type="application/atom+xml" href="https://example.com/feed.atom"></link>`,
+		`<id>tag:example.com,2022:snippet/2</id>`,
+		`<content type="text">if a &lt; b &amp;&amp; c &gt; d {}</content>`,
+		`<name>Alice</name>`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("want %s to contain %q", b, want)
+		}
+	}
+}
+
+func TestRSS(t *testing.T) {
+	b, err := testFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">`,
+		`<lastBuildDate>Thu, 03 Mar 2022 10:00:00 +0000</lastBuildDate>`,
+		`<guid isPermaLink="false">tag:example.com,2022:snippet/1</guid>`,
+		`<pubDate>Tue, 01 Mar 2022 10:00:00 +0000</pubDate>`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("want %s to contain %q", b, want)
+		}
+	}
+}
+
+func TestUpdated(t *testing.T) {
+	modified := time.Date(2022, 3, 4, 10, 0, 0, 0, time.UTC)
+	entries := []*Entry{{Updated: time.Date(2022, 3, 3, 10, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		name string
+		feed *Feed
+		want time.Time
+	}{
+		{"Entries", &Feed{Entries: entries}, entries[0].Updated},
+		{"Modified after the entries", &Feed{Modified: modified, Entries: entries}, modified},
+		{"Modified without entries", &Feed{Modified: modified}, modified},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.feed.Updated(); !got.Equal(test.want) {
+				t.Errorf("want %v; got %v", test.want, got)
+			}
+		})
+	}
+
+	// A feed without any known time is updated now, instead of in year 1.
+	before := time.Now()
+	if got := (&Feed{}).Updated(); got.Before(before) {
+		t.Errorf("want the current time; got %v", got)
+	}
+}
@@ -11,6 +11,20 @@ import (
 // EmailRX is a compiled pattern for checking email address.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX is a compiled pattern for checking a single tag: lowercase letters and digits,
+// optionally separated by single hyphens.
+var TagRX = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
+// tagMaxLength is the maximum number of characters in a single tag.
+const tagMaxLength = 30
+
+// FileNameRX is a compiled pattern for checking a file name: letters, digits, dots,
+// hyphens and underscores, not starting with a dot.
+var FileNameRX = regexp.MustCompile("^[A-Za-z0-9_-][A-Za-z0-9._-]*$")
+
+// fileNameMaxLength is the maximum number of characters in a file name.
+const fileNameMaxLength = 100
+
 // Form embeds a url.Values and Errors field to hold any validation errors for the form data.
 type Form struct {
 	url.Values
@@ -86,6 +100,97 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// Tags split the comma-separated value of given field into a list of tags.
+// Each tag is trimmed and lower-cased, and blank or repeated tags are dropped.
+func (f *Form) Tags(field string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(f.Get(field), ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// ValidTags check the comma-separated tags in the given field matches TagRX, are not
+// too long and there are at most max tags. If it fails then add an error message into f.Errors.
+func (f *Form) ValidTags(field string, max int) {
+	tags := f.Tags(field)
+	if len(tags) > max {
+		f.Errors.Add(field, fmt.Sprintf("This field has too many tags (maximum is %d)", max))
+		return
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > tagMaxLength {
+			f.Errors.Add(field, fmt.Sprintf("Tag %q is too long (maximum is %d characters)", tag, tagMaxLength))
+			return
+		}
+		if !TagRX.MatchString(tag) {
+			f.Errors.Add(field, fmt.Sprintf("Tag %q is invalid (use letters, digits and hyphens only)", tag))
+			return
+		}
+	}
+}
+
+// ValidFiles check the files given by the repeated values of nameField and contentField.
+// There must be between 1 and max files, each with a content. A name is optional only
+// when there is a single file, and names must match FileNameRX and be unique. The errors
+// of the i-th file are added into f.Errors as "nameField.i" and "contentField.i", and
+// the errors of the whole list as nameField.
+func (f *Form) ValidFiles(nameField, contentField string, max int) {
+	names, contents := f.Values[nameField], f.Values[contentField]
+	if len(contents) == 0 {
+		f.Errors.Add(nameField, "At least one file is required")
+		return
+	}
+	if len(contents) > max {
+		f.Errors.Add(nameField, fmt.Sprintf("There are too many files (maximum is %d)", max))
+		return
+	}
+	if len(names) != len(contents) {
+		f.Errors.Add(nameField, "Every file must have a name and a content")
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, name := range names {
+		nameKey, contentKey := fmt.Sprintf("%s.%d", nameField, i), fmt.Sprintf("%s.%d", contentField, i)
+		if strings.TrimSpace(contents[i]) == "" {
+			f.Errors.Add(contentKey, "This field cannot be blank")
+		}
+
+		switch {
+		case name == "" && len(names) == 1:
+		case name == "":
+			f.Errors.Add(nameKey, "This field cannot be blank")
+		case utf8.RuneCountInString(name) > fileNameMaxLength:
+			f.Errors.Add(nameKey, fmt.Sprintf("This field is too long (maximum is %d character)", fileNameMaxLength))
+		case !FileNameRX.MatchString(name):
+			f.Errors.Add(nameKey, "This field is invalid (use letters, digits, dots, hyphens and underscores only)")
+		case seen[name]:
+			f.Errors.Add(nameKey, fmt.Sprintf("There is already a file named %q", name))
+		}
+		seen[name] = true
+	}
+}
+
+// ValidURL check if the value of given field is an absolute http or https URL with a host.
+// If it fails then add an error message into f.Errors.
+func (f *Form) ValidURL(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		f.Errors.Add(field, "This field must be an http or https URL")
+	}
+}
+
 // Valid return true if there is no error in the Form.
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
@@ -0,0 +1,116 @@
+package forms
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"Empty", "", []string{}},
+		{"Single", "go", []string{"go"}},
+		{"Trim and lower", " Go , HTTP ", []string{"go", "http"}},
+		{"Blank and repeated", "go,, go,sql,", []string{"go", "sql"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := New(url.Values{"tags": {test.value}})
+			got := f.Tags("tags")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want %q; got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantValid bool
+	}{
+		{"Empty", "", true},
+		{"Valid", "go, web-dev, sql2", true},
+		{"Max count", "a, b, c", true},
+		{"Too many", "a, b, c, d", false},
+		{"Invalid character", "c++", false},
+		{"Leading hyphen", "-go", false},
+		{"Too long", "abcdefghijklmnopqrstuvwxyz01234", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := New(url.Values{"tags": {test.value}})
+			f.ValidTags("tags", 3)
+			if f.Valid() != test.wantValid {
+				t.Errorf("want valid %t; got %t (%q)", test.wantValid, f.Valid(), f.Errors.Get("tags"))
+			}
+		})
+	}
+}
+
+func TestValidFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		contents  []string
+		wantValid bool
+		wantError string
+	}{
+		{"Single unnamed file", []string{""}, []string{"x"}, true, ""},
+		{"Several files", []string{"Dockerfile", "docker-compose.yml"}, []string{"x", "y"}, true, ""},
+		{"No file", nil, nil, false, "file_name"},
+		{"Too many", []string{"a", "b", "c"}, []string{"x", "y", "z"}, false, "file_name"},
+		{"Mismatched lists", []string{"a"}, []string{"x", "y"}, false, "file_name"},
+		{"Blank content", []string{"a", "b"}, []string{"x", " "}, false, "file_content.1"},
+		{"Unnamed among several", []string{"a", ""}, []string{"x", "y"}, false, "file_name.1"},
+		{"Path in name", []string{"../a"}, []string{"x"}, false, "file_name.0"},
+		{"Hidden file", []string{".env"}, []string{"x"}, false, "file_name.0"},
+		{"Duplicate name", []string{"a", "a"}, []string{"x", "y"}, false, "file_name.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := New(url.Values{"file_name": test.names, "file_content": test.contents})
+			f.ValidFiles("file_name", "file_content", 2)
+			if f.Valid() != test.wantValid {
+				t.Errorf("want valid %t; got %t (%v)", test.wantValid, f.Valid(), f.Errors)
+			}
+			if test.wantError != "" && f.Errors.Get(test.wantError) == "" {
+				t.Errorf("want an error on %q; got %v", test.wantError, f.Errors)
+			}
+		})
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantValid bool
+	}{
+		{"Empty", "", true},
+		{"HTTPS", "https://chat.example.com/hooks/1?token=abc", true},
+		{"HTTP with port", "http://127.0.0.1:8080/hook", true},
+		{"Other scheme", "ftp://example.com/hook", false},
+		{"Relative", "/hooks/1", false},
+		{"No host", "https:///hook", false},
+		{"Malformed", "http://[::1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := New(url.Values{"url": {test.value}})
+			f.ValidURL("url")
+			if f.Valid() != test.wantValid {
+				t.Errorf("want valid %t; got %t (%q)", test.wantValid, f.Valid(), f.Errors.Get("url"))
+			}
+		})
+	}
+}
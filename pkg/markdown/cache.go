@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"container/list"
+	"html/template"
+	"sync"
+)
+
+// Cache keeps the rendered HTML of the latest revision of up to size documents, so
+// that a document is only rendered again when it changes. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Front is the most recently used.
+	entries map[int]*list.Element
+}
+
+// cacheEntry is a rendered revision of a document.
+type cacheEntry struct {
+	id       int
+	revision int
+	html     template.HTML
+}
+
+// NewCache return an empty cache holding up to size documents.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: map[int]*list.Element{},
+	}
+}
+
+// Render return the HTML of the document with given id and revision, rendering src
+// with Render only if that revision is not cached yet.
+func (c *Cache) Render(id, revision int, src string) (template.HTML, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[id]; ok && e.Value.(*cacheEntry).revision == revision {
+		c.order.MoveToFront(e)
+		html := e.Value.(*cacheEntry).html
+		c.mu.Unlock()
+		return html, nil
+	}
+	c.mu.Unlock()
+
+	// Render without holding the lock, as it is the slow part.
+	html, err := Render(src)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		// Keep the newest revision if another request rendered the document meanwhile.
+		if entry := e.Value.(*cacheEntry); entry.revision <= revision {
+			entry.revision, entry.html = revision, html
+		}
+		c.order.MoveToFront(e)
+		return html, nil
+	}
+	c.entries[id] = c.order.PushFront(&cacheEntry{id: id, revision: revision, html: html})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).id)
+	}
+	return html, nil
+}
+
+// Len return the number of documents in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
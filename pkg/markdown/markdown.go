@@ -0,0 +1,144 @@
+// Package markdown renders Markdown written by users into HTML which is safe to embed
+// in our pages: raw HTML is stripped, dangerous URLs are dropped and every link gets
+// rel="nofollow".
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// full converts the Markdown of documents such as runbooks, with GitHub flavored
+// extensions and fenced code blocks highlighted by their language.
+var full = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("github"),
+			highlighting.WithFormatOptions(chromahtml.TabWidth(4)),
+		),
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&safeRenderer{}, 100)),
+	),
+)
+
+// restricted converts the Markdown of short texts such as comments, where headings
+// and images are rendered as plain text.
+var restricted = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		renderer.WithNodeRenderers(util.Prioritized(&safeRenderer{restricted: true}, 100)),
+	),
+)
+
+// Render converts the Markdown document in src into HTML.
+func Render(src string) (template.HTML, error) {
+	return convert(full, src)
+}
+
+// RenderRestricted converts the Markdown in src into HTML, allowing only the inline
+// formatting, lists, quotes and code blocks.
+func RenderRestricted(src string) (template.HTML, error) {
+	return convert(restricted, src)
+}
+
+// convert converts src into HTML with the given goldmark instance.
+func convert(md goldmark.Markdown, src string) (template.HTML, error) {
+	buf := new(bytes.Buffer)
+	if err := md.Convert([]byte(src), buf); err != nil {
+		return "", err
+	}
+	// The renderer never outputs the raw HTML in src, so the result is safe.
+	return template.HTML(buf.String()), nil
+}
+
+// safeRenderer renders the links with rel="nofollow". If restricted is set, then it
+// also renders the headings as paragraphs and the images as their alternative texts.
+type safeRenderer struct {
+	restricted bool
+}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *safeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindLink, r.renderLink)
+	reg.Register(ast.KindAutoLink, r.renderAutoLink)
+	if r.restricted {
+		reg.Register(ast.KindHeading, r.renderHeading)
+		reg.Register(ast.KindImage, r.renderImage)
+	}
+}
+
+// renderLink renders a link like the default renderer of goldmark, plus rel="nofollow".
+func (r *safeRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Link)
+	if !entering {
+		w.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<a href="`)
+	if !html.IsDangerousURL(n.Destination) {
+		w.Write(util.EscapeHTML(util.URLEscape(n.Destination, true)))
+	}
+	w.WriteByte('"')
+	if n.Title != nil {
+		w.WriteString(` title="`)
+		html.DefaultWriter.Write(w, n.Title)
+		w.WriteByte('"')
+	}
+	w.WriteString(` rel="nofollow">`)
+	return ast.WalkContinue, nil
+}
+
+// renderAutoLink renders an autolink like the default renderer of goldmark, plus rel="nofollow".
+func (r *safeRenderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.AutoLink)
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	url := n.URL(source)
+	if n.AutoLinkType == ast.AutoLinkEmail && !bytes.HasPrefix(bytes.ToLower(url), []byte("mailto:")) {
+		url = append([]byte("mailto:"), url...)
+	}
+
+	w.WriteString(`<a href="`)
+	if !html.IsDangerousURL(url) {
+		w.Write(util.EscapeHTML(util.URLEscape(url, false)))
+	}
+	w.WriteString(`" rel="nofollow">`)
+	w.Write(util.EscapeHTML(n.Label(source)))
+	w.WriteString("</a>")
+	return ast.WalkContinue, nil
+}
+
+// renderHeading renders a heading as a paragraph of strong text.
+func (r *safeRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString("<p><strong>")
+	} else {
+		w.WriteString("</strong></p>\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderImage renders an image as its alternative text.
+func (r *safeRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.Write(util.EscapeHTML(node.Text(source)))
+	}
+	return ast.WalkSkipChildren, nil
+}
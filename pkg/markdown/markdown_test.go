@@ -0,0 +1,107 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderRestricted(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		want     string
+		dontWant string
+	}{
+		{"Emphasis", "*hello*", "<em>hello</em>", ""},
+		{"Code span", "`x := 1`", "<code>x := 1</code>", ""},
+		{"Link", "[go](https://golang.org)", `<a href="https://golang.org" rel="nofollow">go</a>`, ""},
+		{"Bare URL", "see https://golang.org", `<a href="https://golang.org" rel="nofollow">https://golang.org</a>`, ""},
+		{"Dangerous link", "[x](javascript:alert(1))", `<a href="" rel="nofollow">x</a>`, "javascript"},
+		{"Raw HTML", "<script>alert(1)</script>", "", "<script>"},
+		{"Inline raw HTML", "a <b onclick='x()'>b</b>", "", "<b"},
+		{"Heading", "# Title", "<p><strong>Title</strong></p>", "<h1>"},
+		{"Image", "![a cat](https://example.com/cat.png)", "a cat", "<img"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := RenderRestricted(test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), test.want) {
+				t.Errorf("want %q to contain %q", got, test.want)
+			}
+			if test.dontWant != "" && strings.Contains(string(got), test.dontWant) {
+				t.Errorf("want %q not to contain %q", got, test.dontWant)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		want     string
+		dontWant string
+	}{
+		{"Heading", "# Title", `<h1 id="title">Title</h1>`, ""},
+		{"Table", "| a |\n|---|\n| 1 |", "<table>", ""},
+		{"Link", "[go](https://golang.org)", `<a href="https://golang.org" rel="nofollow">go</a>`, ""},
+		{"Raw HTML", "<script>alert(1)</script>", "", "<script>"},
+		{"Highlighted code", "```go\nfunc main() {}\n```", `<span style="`, ""},
+		{"Escaped code", "```html\n<script>\n```", "&lt;", "<script>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Render(test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), test.want) {
+				t.Errorf("want %q to contain %q", got, test.want)
+			}
+			if test.dontWant != "" && strings.Contains(string(got), test.dontWant) {
+				t.Errorf("want %q not to contain %q", got, test.dontWant)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+
+	html, err := c.Render(1, 1, "*one*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "<em>one</em>") {
+		t.Errorf("want %q to contain %q", html, "<em>one</em>")
+	}
+
+	// The same revision is served from the cache, even if given another source.
+	html, _ = c.Render(1, 1, "*changed*")
+	if !strings.Contains(string(html), "<em>one</em>") {
+		t.Errorf("want cached %q to contain %q", html, "<em>one</em>")
+	}
+
+	// A new revision is rendered again.
+	html, _ = c.Render(1, 2, "*changed*")
+	if !strings.Contains(string(html), "<em>changed</em>") {
+		t.Errorf("want %q to contain %q", html, "<em>changed</em>")
+	}
+
+	// The least recently used document is evicted.
+	c.Render(2, 1, "two")
+	c.Render(1, 2, "")
+	c.Render(3, 1, "three")
+	if c.Len() != 2 {
+		t.Errorf("want %d documents; got %d", 2, c.Len())
+	}
+	html, _ = c.Render(2, 1, "*two again*")
+	if !strings.Contains(string(html), "<em>two again</em>") {
+		t.Errorf("want evicted document rendered again; got %q", html)
+	}
+}
@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+var mockAuditEvent = &models.AuditEvent{
+	ID:        1,
+	ActorID:   1,
+	Action:    "login.success",
+	Target:    "user:1",
+	IP:        "192.0.2.1",
+	UserAgent: "Go-http-client/1.1",
+	Created:   time.Now(),
+}
+
+type AuditModel struct{}
+
+func (m *AuditModel) Insert(e *models.AuditEvent) error {
+	return nil
+}
+
+func (m *AuditModel) List(filter models.AuditFilter, limit, offset int) ([]*models.AuditEvent, int, error) {
+	if filter.Action != "" && filter.Action != mockAuditEvent.Action {
+		return []*models.AuditEvent{}, 0, nil
+	}
+	if filter.ActorID > 0 && filter.ActorID != mockAuditEvent.ActorID {
+		return []*models.AuditEvent{}, 0, nil
+	}
+	return []*models.AuditEvent{mockAuditEvent}, 1, nil
+}
@@ -0,0 +1,81 @@
+package mock
+
+import (
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+var mockCollection = &models.Collection{
+	ID:         1,
+	UserID:     1,
+	UserName:   "Alice",
+	Name:       "Onboarding",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Size:       2,
+}
+
+var mockPrivateCollection = &models.Collection{
+	ID:         2,
+	UserID:     1,
+	UserName:   "Alice",
+	Name:       "Drafts",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Size:       0,
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(userID int, name, visibility string) (int, error) {
+	return 3, nil
+}
+
+func (m *CollectionModel) Get(id int) (*models.Collection, error) {
+	switch id {
+	case 1:
+		return mockCollection, nil
+	case 2:
+		return mockPrivateCollection, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CollectionModel) ByUser(userID int) ([]*models.Collection, error) {
+	switch userID {
+	case 1:
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	default:
+		return []*models.Collection{}, nil
+	}
+}
+
+func (m *CollectionModel) Delete(id int) error {
+	return nil
+}
+
+func (m *CollectionModel) Snippets(id int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockSnippet, mockPrivateSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *CollectionModel) AddSnippet(id, snippetID int) error {
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(id, snippetID int) error {
+	return nil
+}
+
+func (m *CollectionModel) MoveSnippet(id, snippetID int, up bool) error {
+	if id != 1 || (snippetID != 1 && snippetID != 3) {
+		return models.ErrNoRecord
+	}
+	return nil
+}
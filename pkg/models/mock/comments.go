@@ -0,0 +1,92 @@
+package mock
+
+import (
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+var (
+	commentCreated    = time.Now()
+	oldCommentCreated = commentCreated.AddDate(-1, 0, 0)
+)
+
+var mockReply = &models.Comment{
+	ID:        2,
+	SnippetID: 1,
+	UserID:    2,
+	UserName:  "Carol",
+	ParentID:  1,
+	Body:      "Thanks for *sharing*!",
+	Created:   commentCreated,
+	Updated:   commentCreated,
+}
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    1,
+	UserName:  "Alice",
+	Body:      "A **classic** by Basho.",
+	Created:   commentCreated,
+	Updated:   commentCreated,
+	Replies:   []*models.Comment{mockReply},
+}
+
+var mockOldComment = &models.Comment{
+	ID:        3,
+	SnippetID: 1,
+	UserID:    1,
+	UserName:  "Alice",
+	Body:      "Written long ago.",
+	Created:   oldCommentCreated,
+	Updated:   oldCommentCreated,
+}
+
+var mockExpiredSnippetComment = &models.Comment{
+	ID:        6,
+	SnippetID: 2,
+	UserID:    1,
+	UserName:  "Alice",
+	Body:      "On a snippet which has expired.",
+	Created:   commentCreated,
+	Updated:   commentCreated,
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID int, body string) (int, error) {
+	return 4, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case 1:
+		return mockComment, nil
+	case 2:
+		return mockReply, nil
+	case 3:
+		return mockOldComment, nil
+	case 6:
+		return mockExpiredSnippetComment, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	return nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	return nil
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Comment{mockComment, mockOldComment}, nil
+	default:
+		return []*models.Comment{}, nil
+	}
+}
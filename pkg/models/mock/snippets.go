@@ -1,34 +1,172 @@
 package mock
 
 import (
+	"context"
 	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:          1,
+	Title:       "An old silent pond",
+	Content:     "An old silent pond...",
+	Files:       []*models.SnippetFile{{Name: "haiku.txt", Content: "An old silent pond..."}},
+	ContentType: models.ContentTypeText,
+	Revision:    1,
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Tags:        []string{"haiku"},
+	UserID:      1,
+	UserName:    "Alice",
+	Visibility:  models.VisibilityPublic,
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:          3,
+	Title:       "A secret note",
+	Content:     "Nobody else can read this...",
+	Files:       []*models.SnippetFile{{Name: "secret.txt", Content: "Nobody else can read this..."}},
+	ContentType: models.ContentTypeText,
+	Revision:    1,
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Tags:        []string{},
+	UserID:      1,
+	UserName:    "Alice",
+	Visibility:  models.VisibilityPrivate,
+}
+
+var mockFork = &models.Snippet{
+	ID:      4,
+	Title:   "An old silent pond, revisited",
+	Content: "An old silent pond...\nA frog jumps into the pond",
+	Files: []*models.SnippetFile{
+		{Name: "pond.txt", Content: "An old silent pond...\nA frog jumps into the pond"},
+		{Name: "splash.txt", Content: "Splash! Silence again."},
+	},
+	ContentType: models.ContentTypeText,
+	Revision:    1,
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Tags:        []string{"haiku"},
+	UserID:      2,
+	UserName:    "Carol",
+	Visibility:  models.VisibilityPublic,
+	ForkedFrom:  1,
+}
+
+var mockMarkdownSnippet = &models.Snippet{
+	ID:      5,
+	Title:   "Restarting the server",
+	Content: "# Restart\n\nRun:\n\n```sh\nsystemctl restart web\n```\n\n<script>alert(1)</script>",
+	Files: []*models.SnippetFile{
+		{Name: "runbook.md", Content: "# Restart\n\nRun:\n\n```sh\nsystemctl restart web\n```\n\n<script>alert(1)</script>"},
+	},
+	ContentType: models.ContentTypeMarkdown,
+	Revision:    2,
+	Created:     time.Now(),
+	Expires:     time.Now(),
+	Tags:        []string{},
+	UserID:      2,
+	UserName:    "Carol",
+	Visibility:  models.VisibilityUnlisted,
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(ctx context.Context, s *models.Snippet, expires string) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockPrivateSnippet, nil
+	case 4:
+		return mockFork, nil
+	case 5:
+		return mockMarkdownSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) Forks(ctx context.Context, id int) ([]*models.Snippet, error) {
+	switch id {
+	case 1:
+		return []*models.Snippet{mockFork}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, sort string, limit, offset int) ([]*models.Snippet, int, error) {
+	if userID != mockSnippet.UserID || offset > 0 {
+		return []*models.Snippet{}, 0, nil
+	}
+	return []*models.Snippet{mockSnippet}, 1, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context, tag, sort string) ([]*models.Snippet, error) {
+	switch tag {
+	case "", "haiku":
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) FeedModified(ctx context.Context, tag string, userID int) (time.Time, error) {
+	if tag == "go" {
+		return time.Time{}, nil
+	}
+	return mockSnippet.Created, nil
+}
+
+func (m *SnippetModel) List(ctx context.Context, limit, offset int) ([]*models.Snippet, int, error) {
+	if offset > 0 {
+		return []*models.Snippet{}, 2, nil
+	}
+	return []*models.Snippet{mockSnippet, mockPrivateSnippet}, 2, nil
+}
+
+func (m *SnippetModel) Find(ctx context.Context, id int) (*models.Snippet, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *SnippetModel) Expired(ctx context.Context, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) MarkExpiryNotified(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) CreatedPerDay(ctx context.Context, days int) ([]*models.DailyCount, error) {
+	return []*models.DailyCount{{Day: mockSnippet.Created, Count: 1}}, nil
+}
+
+func (m *SnippetModel) ExpiredPerDay(ctx context.Context, days int) ([]*models.DailyCount, error) {
+	return []*models.DailyCount{}, nil
 }
@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+type StarModel struct{}
+
+func (m *StarModel) Star(ctx context.Context, userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Unstar(ctx context.Context, userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) IsStarred(ctx context.Context, userID, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == 1, nil
+}
+
+func (m *StarModel) Count(ctx context.Context, snippetID int) (int, error) {
+	switch snippetID {
+	case 1:
+		return 3, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m *StarModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *StarModel) MostStarredSince(ctx context.Context, since time.Time, limit int) ([]*models.Snippet, error) {
+	s := *mockSnippet
+	s.Stars = 3
+	return []*models.Snippet{&s}, nil
+}
@@ -0,0 +1,11 @@
+package mock
+
+import (
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) Popular(limit int) ([]*models.Tag, error) {
+	return []*models.Tag{{Name: "haiku", Count: 1}}, nil
+}
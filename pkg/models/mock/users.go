@@ -1,6 +1,8 @@
 package mock
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/models"
@@ -14,39 +16,81 @@ var mockUser = &models.User{
 	Active:  true,
 }
 
+var mockAdmin = &models.User{
+	ID:      2,
+	Name:    "Carol",
+	Email:   "carol@example.com",
+	Created: time.Now(),
+	Active:  true,
+	Admin:   true,
+}
+
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (int, error) {
 	switch email {
 	case "dup@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 3, nil
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	switch email {
 	case "alice@example.com":
 		return 1, nil
+	case "carol@example.com":
+		return 2, nil
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockAdmin, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
 // TODO: Mock the method ChangePassword
-func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
 	if id != 1 {
 		return models.ErrInvalidCredentials
 	}
 	return nil
 }
+
+func (m *UserModel) Search(ctx context.Context, query string, limit, offset int) ([]*models.User, int, error) {
+	users := []*models.User{}
+	for _, u := range []*models.User{mockUser, mockAdmin} {
+		if strings.Contains(u.Name, query) || strings.Contains(u.Email, query) {
+			users = append(users, u)
+		}
+	}
+	total := len(users)
+	if offset >= total {
+		return []*models.User{}, total, nil
+	}
+	if offset+limit < total {
+		users = users[:offset+limit]
+	}
+	return users[offset:], total, nil
+}
+
+func (m *UserModel) SetActive(ctx context.Context, id int, active bool) error {
+	return nil
+}
+
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) (string, error) {
+	return "temporaryPa$$word", nil
+}
+
+func (m *UserModel) SignupsPerDay(ctx context.Context, days int) ([]*models.DailyCount, error) {
+	return []*models.DailyCount{{Day: mockUser.Created, Count: 2}}, nil
+}
@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+type ViewModel struct{}
+
+func (m *ViewModel) Add(ctx context.Context, day time.Time, counts map[int]int) error {
+	return nil
+}
+
+func (m *ViewModel) Total(ctx context.Context, snippetID int) (int, error) {
+	switch snippetID {
+	case 1:
+		return 42, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (m *ViewModel) PerDay(ctx context.Context, snippetID, days int) ([]*models.DailyCount, error) {
+	switch snippetID {
+	case 1:
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		return []*models.DailyCount{
+			{Day: today.AddDate(0, 0, -1), Count: 30},
+			{Day: today, Count: 12},
+		}, nil
+	default:
+		return []*models.DailyCount{}, nil
+	}
+}
@@ -0,0 +1,100 @@
+package mock
+
+import (
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+var mockWebhook = &models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://chat.example.com/hooks/snippets",
+	Secret:  "5f0d3c1e8b7a69245f0d3c1e8b7a69245f0d3c1e8b7a69245f0d3c1e8b7a6924",
+	Created: time.Now(),
+}
+
+var mockOtherWebhook = &models.Webhook{
+	ID:      2,
+	UserID:  2,
+	URL:     "https://ci.example.com/hooks/snippets",
+	Secret:  "a1b2c3d4e5f60718a1b2c3d4e5f60718a1b2c3d4e5f60718a1b2c3d4e5f60718",
+	Created: time.Now(),
+}
+
+type WebhookModel struct{}
+
+func (m *WebhookModel) Insert(userID int, url, secret string) (int, error) {
+	return 3, nil
+}
+
+func (m *WebhookModel) Get(id int) (*models.Webhook, error) {
+	switch id {
+	case 1:
+		return mockWebhook, nil
+	case 2:
+		return mockOtherWebhook, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *WebhookModel) ByUser(userID int) ([]*models.Webhook, error) {
+	switch userID {
+	case 1:
+		return []*models.Webhook{mockWebhook}, nil
+	case 2:
+		return []*models.Webhook{mockOtherWebhook}, nil
+	default:
+		return []*models.Webhook{}, nil
+	}
+}
+
+func (m *WebhookModel) Delete(id int) error {
+	return nil
+}
+
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	return nil
+}
+
+func (m *WebhookModel) Due(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return []*models.WebhookDelivery{}, nil
+}
+
+func (m *WebhookModel) RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error {
+	return nil
+}
+
+func (m *WebhookModel) Deliveries(webhookID, limit int) ([]*models.WebhookDelivery, error) {
+	if webhookID != 1 {
+		return []*models.WebhookDelivery{}, nil
+	}
+	return []*models.WebhookDelivery{
+		{
+			ID:           2,
+			WebhookID:    1,
+			Event:        "snippet.deleted",
+			Payload:      []byte(`{"event":"snippet.deleted"}`),
+			Status:       models.DeliveryPending,
+			Attempts:     2,
+			NextAttempt:  time.Now().Add(time.Minute),
+			ResponseCode: 503,
+			Error:        "unexpected response status 503 Service Unavailable",
+			Created:      time.Now(),
+			Updated:      time.Now(),
+		},
+		{
+			ID:           1,
+			WebhookID:    1,
+			Event:        "snippet.created",
+			Payload:      []byte(`{"event":"snippet.created"}`),
+			Status:       models.DeliveryDelivered,
+			Attempts:     1,
+			NextAttempt:  time.Now(),
+			ResponseCode: 204,
+			Created:      time.Now(),
+			Updated:      time.Now(),
+		},
+	}, nil
+}
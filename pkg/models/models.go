@@ -11,13 +11,101 @@ var (
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
 )
 
+// Visibilities of a snippet.
+const (
+	// VisibilityPublic snippets are listed everywhere.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted snippets are not listed, but anyone with the URL can see them.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate snippets can only be seen by their owners.
+	VisibilityPrivate = "private"
+)
+
+// Sort orders of the listings of snippets.
+const (
+	// SortLatest lists the most recently created snippets first.
+	SortLatest = "latest"
+	// SortPopular lists the most viewed snippets first.
+	SortPopular = "popular"
+)
+
+// Content types of a snippet.
+const (
+	// ContentTypeText snippets are shown as they are, such as source code.
+	ContentTypeText = "text"
+	// ContentTypeMarkdown snippets are rendered as Markdown documents.
+	ContentTypeMarkdown = "markdown"
+)
+
+// States of a webhook delivery.
+const (
+	// DeliveryPending deliveries are waiting for their next attempt.
+	DeliveryPending = "pending"
+	// DeliveryDelivered deliveries have been accepted by the receiver.
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed deliveries have failed too many times and are not retried any more.
+	DeliveryFailed = "failed"
+)
+
 // Snippet define the structure of a snippet retrieved from the database.
 type Snippet struct {
 	ID      int
 	Title   string
 	Content string
-	Created time.Time
-	Expires time.Time
+	// ContentType is how the content is shown, one of the ContentType constants.
+	ContentType string
+	// Revision is incremented each time the content changes, starting from 1.
+	Revision int
+	Created  time.Time
+	Expires  time.Time
+	Hidden   bool
+	Tags     []string
+	// UserID is the id of the owner, or 0 for the snippets created before snippets had owners.
+	UserID     int
+	UserName   string
+	Visibility string
+	// Stars is the number of stars, only filled in by the listings ordered by stars.
+	Stars int
+	// ForkedFrom is the id of the snippet this one was forked from, or 0 if it is not a fork.
+	ForkedFrom int
+	// Files are the named files of the snippet, only filled in by Get. Content is always
+	// the content of the first file.
+	Files []*SnippetFile
+}
+
+// DefaultFileName return the name of the file of a snippet with only one unnamed file,
+// according to the content type of the snippet.
+func DefaultFileName(contentType string) string {
+	if contentType == ContentTypeMarkdown {
+		return "snippet.md"
+	}
+	return "snippet.txt"
+}
+
+// Collection is a named list of snippets curated by a user.
+type Collection struct {
+	ID       int
+	UserID   int
+	UserName string
+	Name     string
+	// Visibility is either VisibilityPublic, anyone with the URL can see the collection,
+	// or VisibilityPrivate.
+	Visibility string
+	Created    time.Time
+	// Size is the number of snippets in the collection.
+	Size int
+}
+
+// SnippetFile is a named file in a snippet.
+type SnippetFile struct {
+	Name    string
+	Content string
+}
+
+// Tag define a tag together with the number of snippets tagged with it.
+type Tag struct {
+	Name  string
+	Count int
 }
 
 // User define the structure of a user retrieved from the database.
@@ -28,4 +116,81 @@ type User struct {
 	HashedPassword []byte
 	Created        time.Time
 	Active         bool
+	Admin          bool
+	// MustResetPassword is set by an admin to force the user to change
+	// their password before they can use the site again.
+	MustResetPassword bool
+}
+
+// DailyCount is the number of records that fall on a given day.
+type DailyCount struct {
+	Day   time.Time
+	Count int
+}
+
+// AuditEvent define the structure of a security-relevant event retrieved from the database.
+type AuditEvent struct {
+	ID int
+	// ActorID is the id of the user who triggered the event, or 0 if anonymous.
+	ActorID   int
+	Action    string
+	Target    string
+	IP        string
+	UserAgent string
+	Created   time.Time
+}
+
+// AuditFilter define the conditions to select audit events. Zero fields match all events.
+type AuditFilter struct {
+	ActorID int
+	Action  string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Comment define the structure of a comment on a snippet retrieved from the database.
+type Comment struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	UserName  string
+	// ParentID is the id of the comment this one replies to, or 0 for a top-level comment.
+	ParentID int
+	Body     string
+	Created  time.Time
+	Updated  time.Time
+	// Replies holds the replies to a top-level comment, oldest first.
+	Replies []*Comment
+}
+
+// Webhook is a URL registered by a user to receive the events on their snippets.
+type Webhook struct {
+	ID     int
+	UserID int
+	URL    string
+	// Secret is the key of the HMAC-SHA256 signature of the payloads sent to the URL.
+	Secret  string
+	Created time.Time
+}
+
+// WebhookDelivery is an event to send to a webhook, together with the outcome of the
+// attempts to send it.
+type WebhookDelivery struct {
+	ID        int
+	WebhookID int
+	// URL and Secret are the ones of the webhook, only filled in by Due.
+	URL     string
+	Secret  string
+	Event   string
+	Payload []byte
+	// Status is one of the Delivery constants.
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	// ResponseCode is the HTTP status of the last attempt, or 0 if there was no response.
+	ResponseCode int
+	// Error describes why the last attempt failed, if it did.
+	Error   string
+	Created time.Time
+	Updated time.Time
 }
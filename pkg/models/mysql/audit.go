@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// AuditModel is a wrapper of sql.DB connection pool toward the append-only
+// audit_events table in db. It never updates nor deletes an event.
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Insert appends an audit event. The Created field of e is ignored and the
+// current time is recorded instead.
+func (m *AuditModel) Insert(e *models.AuditEvent) error {
+	stmt := `INSERT INTO audit_events (actor_id, action, target, ip, user_agent, created)
+		VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	// Anonymous events, e.g. failed logins, are stored with a NULL actor.
+	_, err := m.DB.Exec(stmt, nullableID(e.ActorID), e.Action, truncate(e.Target, 255), e.IP, truncate(e.UserAgent, 255))
+	return err
+}
+
+// List return the audit events matching the filter, latest first, together with
+// the total number of matching events. A limit of 0 return all matching events.
+func (m *AuditModel) List(filter models.AuditFilter, limit, offset int) ([]*models.AuditEvent, int, error) {
+	// Build the WHERE clause from the non-zero fields of the filter.
+	conds := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.ActorID > 0 {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		conds = append(conds, "created >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conds = append(conds, "created < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	where := strings.Join(conds, " AND ")
+
+	var total int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM audit_events WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, actor_id, action, target, ip, user_agent, created FROM audit_events
+		WHERE ` + where + ` ORDER BY id DESC`
+	if limit > 0 {
+		stmt += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*models.AuditEvent{}
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var actorID sql.NullInt64
+		err = rows.Scan(&e.ID, &actorID, &e.Action, &e.Target, &e.IP, &e.UserAgent, &e.Created)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.ActorID = int(actorID.Int64)
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,171 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// CollectionModel is a wrapper of sql.DB connection pool toward the collections of snippets.
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a new empty collection owned by the user and return its id.
+func (m *CollectionModel) Insert(userID int, name, visibility string) (int, error) {
+	stmt := `INSERT INTO collections (user_id, name, visibility, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, userID, name, visibility)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Get return a specific collection based on given id.
+func (m *CollectionModel) Get(id int) (*models.Collection, error) {
+	stmt := `SELECT c.id, c.user_id, u.name, c.name, c.visibility, c.created,
+		(SELECT COUNT(*) FROM collection_snippets cs WHERE cs.collection_id = c.id)
+		FROM collections c JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c := &models.Collection{}
+	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.UserID, &c.UserName, &c.Name, &c.Visibility, &c.Created, &c.Size)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// ByUser return all the collections owned by the user in alphabetical order.
+func (m *CollectionModel) ByUser(userID int) ([]*models.Collection, error) {
+	stmt := `SELECT c.id, c.user_id, u.name, c.name, c.visibility, c.created,
+		(SELECT COUNT(*) FROM collection_snippets cs WHERE cs.collection_id = c.id)
+		FROM collections c JOIN users u ON u.id = c.user_id WHERE c.user_id = ? ORDER BY c.name, c.id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c := &models.Collection{}
+		err = rows.Scan(&c.ID, &c.UserID, &c.UserName, &c.Name, &c.Visibility, &c.Created, &c.Size)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// Delete deletes a specific collection, but not the snippets in it.
+func (m *CollectionModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM collections WHERE id = ?`, id)
+	return err
+}
+
+// Snippets return the snippets in a specific collection in their order, leaving out the
+// expired and hidden ones. The caller still has to check the visibility of each snippet.
+func (m *CollectionModel) Snippets(id int) ([]*models.Snippet, error) {
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires, s.user_id, s.visibility
+		FROM collection_snippets cs JOIN snippets s ON s.id = cs.snippet_id
+		WHERE cs.collection_id = ? AND s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE
+		ORDER BY cs.position`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		var userID sql.NullInt64
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &userID, &s.Visibility)
+		if err != nil {
+			return nil, err
+		}
+		s.UserID = int(userID.Int64)
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// AddSnippet appends the snippet to the end of the collection. Adding a snippet twice is a no-op.
+func (m *CollectionModel) AddSnippet(id, snippetID int) error {
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID, id)
+	return err
+}
+
+// RemoveSnippet removes the snippet from the collection, if it is in it.
+func (m *CollectionModel) RemoveSnippet(id, snippetID int) error {
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID)
+	return err
+}
+
+// MoveSnippet swaps the snippet with the previous one in the collection if up is true,
+// or with the next one otherwise. Moving the first snippet up or the last one down is a no-op.
+func (m *CollectionModel) MoveSnippet(id, snippetID int, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op if the transaction has been committed.
+	defer tx.Rollback()
+
+	var position int
+	stmt := `SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, id, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+
+	// Find the neighbour to swap with.
+	stmt = `SELECT snippet_id, position FROM collection_snippets
+		WHERE collection_id = ? AND position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if up {
+		stmt = `SELECT snippet_id, position FROM collection_snippets
+			WHERE collection_id = ? AND position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+	var otherID, otherPosition int
+	err = tx.QueryRow(stmt, id, position).Scan(&otherID, &otherPosition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+	if _, err = tx.Exec(stmt, otherPosition, id, snippetID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(stmt, position, id, otherID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
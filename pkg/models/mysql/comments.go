@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// CommentModel is a wrapper of sql.DB connection pool toward the comments on snippets in db.
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// Insert inserts a new comment of the user on the snippet, replying to the comment
+// with parentID if it is not 0, and return the id of the new comment.
+func (m *CommentModel) Insert(snippetID, userID, parentID int, body string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, body, created, updated)
+		VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, snippetID, userID, nullableID(parentID), body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Get return the comment with given id, without its replies.
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, c.user_id, u.name, c.parent_id, c.body, c.created, c.updated
+		FROM comments c JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update replaces the body of the comment with given id.
+func (m *CommentModel) Update(id int, body string) error {
+	stmt := `UPDATE comments SET body = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := m.DB.Exec(stmt, body, id)
+	return err
+}
+
+// Delete deletes the comment with given id together with its replies.
+func (m *CommentModel) Delete(id int) error {
+	// The replies are deleted by the ON DELETE CASCADE of comments.parent_id.
+	_, err := m.DB.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	return err
+}
+
+// ForSnippet return the top-level comments on the snippet with their replies, oldest first.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, c.user_id, u.name, c.parent_id, c.body, c.created, c.updated
+		FROM comments c JOIN users u ON u.id = c.user_id
+		WHERE c.snippet_id = ? ORDER BY c.created, c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Since the replies are always created after their parents, each parent has
+	// been seen before its replies.
+	comments := []*models.Comment{}
+	parents := map[int]*models.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ParentID == 0 {
+			comments = append(comments, c)
+			parents[c.ID] = c
+		} else if p, ok := parents[c.ParentID]; ok {
+			p.Replies = append(p.Replies, c)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// scanner is implemented by both sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanComment scans a comment from the columns selected by Get and ForSnippet.
+func scanComment(row scanner) (*models.Comment, error) {
+	c := &models.Comment{}
+	var parentID sql.NullInt64
+	err := row.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.UserName, &parentID, &c.Body, &c.Created, &c.Updated)
+	if err != nil {
+		return nil, err
+	}
+	c.ParentID = int(parentID.Int64)
+	return c, nil
+}
@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// withTimeout return ctx with the given timeout, or ctx itself if the timeout is 0.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryDailyCounts executes the given statement which selects a day and a count
+// in each row, and return the rows as daily counts.
+func queryDailyCounts(ctx context.Context, db *sql.DB, stmt string, args ...interface{}) ([]*models.DailyCount, error) {
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*models.DailyCount{}
+	for rows.Next() {
+		c := &models.DailyCount{}
+		if err = rows.Scan(&c.Day, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// snippetOrder return the ORDER BY clause listing the snippets aliased as s in the given
+// sort order. Unknown sort orders list the latest snippets first.
+func snippetOrder(sort string) string {
+	if sort == models.SortPopular {
+		return `ORDER BY (SELECT COALESCE(SUM(v.views), 0) FROM snippet_views v WHERE v.snippet_id = s.id) DESC,
+			s.created DESC`
+	}
+	return `ORDER BY s.created DESC`
+}
+
+// likeEscaper escapes the wildcards of the LIKE operator.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes s so that it is matched literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// truncate cuts s down to at most n characters so that it fits into a VARCHAR(n) column.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// nullableID return id as a nullable column value, where 0 is stored as NULL.
+func nullableID(id int) sql.NullInt64 {
+	if id == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(id), Valid: true}
+}
+
+// querySnippets executes the given statement which selects the id, title, content,
+// created and expires columns of snippets, and return the rows as snippets.
+func querySnippets(ctx context.Context, db *sql.DB, stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{"No timeout", 0, false},
+		{"Timeout", time.Second, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := withTimeout(context.Background(), test.timeout)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if ok != test.wantDeadline {
+				t.Fatalf("want deadline %t; got %t", test.wantDeadline, ok)
+			}
+			if ok && time.Until(deadline) > test.timeout {
+				t.Errorf("want deadline within %v; got %v", test.timeout, time.Until(deadline))
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/models"
 )
@@ -10,17 +12,36 @@ import (
 // SnippetModel is a wrapper of sql.DB connection pool.
 type SnippetModel struct {
 	DB *sql.DB
+	// Timeout bounds the queries of each method call, unless it is 0.
+	Timeout time.Duration
 }
 
-// Insert inserts a new snippet into the database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+// Insert inserts a new snippet with its tags into the database. The snippet expires
+// in given number of days. The ID, Created and Expires fields of s are ignored.
+func (m *SnippetModel) Insert(ctx context.Context, s *models.Snippet, expires string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Insert")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// Insert the snippet and its tags in a transaction, so that a snippet is never
+	// stored with only some of its tags.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op if the transaction has been committed.
+	defer tx.Rollback()
+
 	// stmt is a statement of inserting data into the database.
 	// '?'s are placeholder parameters.
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-		VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (user_id, forked_from, title, content, content_type, visibility, created,
+		expires, updated) VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY),
+		UTC_TIMESTAMP())`
 
-	// Use DB.Exec() to execute the statement with placeholder parameters and get the result.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// Use Exec() to execute the statement with placeholder parameters and get the result.
+	result, err := tx.ExecContext(ctx, stmt, nullableID(s.UserID), nullableID(s.ForkedFrom), s.Title, s.Content,
+		s.ContentType, s.Visibility, expires)
 	if err != nil {
 		return 0, err
 	}
@@ -31,16 +52,66 @@ func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
 		return 0, err
 	}
 
+	if err = insertSnippetTags(ctx, tx, int(id), s.Tags); err != nil {
+		return 0, err
+	}
+
+	if err = insertSnippetFiles(ctx, tx, int(id), s.Files); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
 	return int(id), nil
 }
 
+// insertSnippetTags tags the snippet with given tags, creating the tags which do not exist yet.
+func insertSnippetTags(ctx context.Context, tx *sql.Tx, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		// LAST_INSERT_ID(id) makes LastInsertId return the id of an existing tag.
+		stmt := `INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`
+		result, err := tx.ExecContext(ctx, stmt, tag)
+		if err != nil {
+			return err
+		}
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)`
+		if _, err = tx.ExecContext(ctx, stmt, snippetID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertSnippetFiles stores the files of the snippet in their order.
+func insertSnippetFiles(ctx context.Context, tx *sql.Tx, snippetID int, files []*models.SnippetFile) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, content) VALUES(?, ?, ?, ?)`
+	for i, f := range files {
+		if _, err := tx.ExecContext(ctx, stmt, snippetID, i, f.Name, f.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Get return a specific snippet based on given id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-		WHERE expires > UTC_TIMESTAMP() AND id = ?`
+func (m *SnippetModel) Get(ctx context.Context, id int) (_ *models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Get")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.title, s.content, s.content_type, s.revision, s.created, s.expires, s.user_id,
+		u.name, s.visibility, s.forked_from FROM snippets s LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE AND s.id = ?`
 
 	// Use DB.QueryRow to retreive the data.
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRowContext(ctx, stmt, id)
 
 	// Initial a pointer to a new zeroed snippet struct
 	s := &models.Snippet{}
@@ -48,7 +119,10 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	// Use row.Scan to copy the value in the row into s.
 	// The number of arguments must be exactly the same as the number of columns
 	// returned by DB.QueryRow.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	var userID, forkedFrom sql.NullInt64
+	var userName sql.NullString
+	err = row.Scan(&s.ID, &s.Title, &s.Content, &s.ContentType, &s.Revision, &s.Created, &s.Expires,
+		&userID, &userName, &s.Visibility, &forkedFrom)
 	if err != nil {
 		// Check if the error is the sql.ErrNoRows error.
 		if errors.Is(err, sql.ErrNoRows) {
@@ -58,15 +132,99 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 		return nil, err
 	}
 
+	s.UserID = int(userID.Int64)
+	s.UserName = userName.String
+	s.ForkedFrom = int(forkedFrom.Int64)
+
+	// Retrieve the tags of this snippet.
+	s.Tags, err = m.tags(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrieve the files of this snippet. Snippets created before snippets had files
+	// hold their only file in the content.
+	s.Files, err = m.files(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Files) == 0 {
+		s.Files = []*models.SnippetFile{{Name: models.DefaultFileName(s.ContentType), Content: s.Content}}
+	}
+
 	return s, nil
 }
 
-// Latest return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-		WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+// files return the files of the snippet with given id in their order.
+func (m *SnippetModel) files(ctx context.Context, id int) ([]*models.SnippetFile, error) {
+	stmt := `SELECT name, content FROM snippet_files WHERE snippet_id = ? ORDER BY position`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.SnippetFile{}
+	for rows.Next() {
+		f := &models.SnippetFile{}
+		if err = rows.Scan(&f.Name, &f.Content); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// tags return the names of the tags on the snippet with given id in alphabetical order.
+func (m *SnippetModel) tags(ctx context.Context, id int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t JOIN snippet_tags st ON st.tag_id = t.id
+		WHERE st.snippet_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Latest return the 10 first public snippets in the given sort order, one of the Sort
+// constants. If tag is not blank, then only the snippets tagged with it are returned.
+func (m *SnippetModel) Latest(ctx context.Context, tag, sort string) (_ []*models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Latest")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires FROM snippets s
+		WHERE s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE AND s.visibility = 'public' ` +
+		snippetOrder(sort) + ` LIMIT 10`
+	args := []interface{}{}
+	if tag != "" {
+		stmt = `SELECT s.id, s.title, s.content, s.created, s.expires FROM snippets s
+			JOIN snippet_tags st ON st.snippet_id = s.id JOIN tags t ON t.id = st.tag_id
+			WHERE s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE AND s.visibility = 'public'
+			AND t.name = ? ` + snippetOrder(sort) + ` LIMIT 10`
+		args = append(args, tag)
+	}
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -96,3 +254,287 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 
 	return snippets, nil
 }
+
+// Forks return the public snippets forked from the snippet with given id, latest first.
+func (m *SnippetModel) Forks(ctx context.Context, id int) (_ []*models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Forks")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, title, content, created, expires FROM snippets
+		WHERE forked_from = ? AND expires > UTC_TIMESTAMP() AND hidden = FALSE AND visibility = 'public'
+		ORDER BY created DESC`
+	return querySnippets(ctx, m.DB, stmt, id)
+}
+
+// ByUser return a page of the public snippets owned by the user with given id in the
+// given sort order, together with the total number of such snippets.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int, sort string, limit, offset int) (_ []*models.Snippet, _ int, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.ByUser")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	where := `WHERE s.user_id = ? AND s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE
+		AND s.visibility = 'public'`
+
+	var total int
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM snippets s `+where, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires FROM snippets s ` + where + ` ` +
+		snippetOrder(sort) + ` LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{UserID: userID, Visibility: models.VisibilityPublic}
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// FeedModified return the last time a public snippet with the tag, or of the user if
+// userID is not 0, was created, hidden, shown or expired, that is the last time the
+// feed of those snippets changed. It return the zero time if there is no such snippet.
+func (m *SnippetModel) FeedModified(ctx context.Context, tag string, userID int) (_ time.Time, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.FeedModified")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// The hidden and expired snippets count too, since they have left the feed.
+	stmt := `SELECT MAX(IF(s.expires <= UTC_TIMESTAMP(), GREATEST(s.updated, s.expires), s.updated))
+		FROM snippets s`
+	where := ` WHERE s.visibility = 'public'`
+	args := []interface{}{}
+	if tag != "" {
+		stmt += ` JOIN snippet_tags st ON st.snippet_id = s.id JOIN tags t ON t.id = st.tag_id`
+		where += ` AND t.name = ?`
+		args = append(args, tag)
+	}
+	if userID != 0 {
+		where += ` AND s.user_id = ?`
+		args = append(args, userID)
+	}
+
+	var modified sql.NullTime
+	err = m.DB.QueryRowContext(ctx, stmt+where, args...).Scan(&modified)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return modified.Time, nil
+}
+
+// List return a page of all the snippets, including hidden and expired ones, together
+// with the total number of snippets. It is meant for moderation only.
+func (m *SnippetModel) List(ctx context.Context, limit, offset int) (_ []*models.Snippet, _ int, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.List")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var total int
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM snippets`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, title, content, created, expires, hidden, user_id, visibility FROM snippets
+		ORDER BY created DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		var userID sql.NullInt64
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Hidden, &userID, &s.Visibility)
+		if err != nil {
+			return nil, 0, err
+		}
+		s.UserID = int(userID.Int64)
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return snippets, total, nil
+}
+
+// Find return a specific snippet based on given id, even if it is hidden or expired.
+// Unlike Get, it does not retrieve the tags and the files of the snippet.
+func (m *SnippetModel) Find(ctx context.Context, id int) (_ *models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Find")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, title, content, content_type, revision, created, expires, hidden, user_id, visibility
+		FROM snippets WHERE id = ?`
+
+	s := &models.Snippet{}
+	var userID sql.NullInt64
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.ContentType, &s.Revision, &s.Created,
+		&s.Expires, &s.Hidden, &userID, &s.Visibility)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	s.UserID = int(userID.Int64)
+	return s, nil
+}
+
+// Expired return at most limit owned snippets which have expired since the last call
+// of MarkExpiryNotified on them, the earliest expired first.
+func (m *SnippetModel) Expired(ctx context.Context, limit int) (_ []*models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Expired")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, title, content_type, created, expires, hidden, user_id, visibility FROM snippets
+		WHERE expires <= UTC_TIMESTAMP() AND expiry_notified = FALSE AND user_id IS NOT NULL
+		ORDER BY expires LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Title, &s.ContentType, &s.Created, &s.Expires, &s.Hidden, &s.UserID,
+			&s.Visibility)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// MarkExpiryNotified records that the expiry of the snippet with given id has been
+// notified, so that Expired does not return it again.
+func (m *SnippetModel) MarkExpiryNotified(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.MarkExpiryNotified")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, `UPDATE snippets SET expiry_notified = TRUE WHERE id = ?`, id)
+	return err
+}
+
+// SetHidden hides or unhides the snippet with given id. Hidden snippets are
+// neither listed nor shown to the users.
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.SetHidden")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// Bump updated, so that the feeds which the snippet leaves or joins are modified.
+	stmt := `UPDATE snippets SET hidden = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+	result, err := m.DB.ExecContext(ctx, stmt, hidden, id)
+	if err != nil {
+		return err
+	}
+
+	// MySQL counts the matched rows, instead of the changed ones, only with
+	// the clientFoundRows option, so check the existence when nothing changed.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		err = m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM snippets WHERE id = ?)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return models.ErrNoRecord
+		}
+	}
+	return nil
+}
+
+// Delete removes the snippet with given id from the database.
+func (m *SnippetModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.Delete")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM snippets WHERE id = ?`
+	result, err := m.DB.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	// Report the missing snippet, so that the caller can response a 404.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// CreatedPerDay return the number of snippets created on each of the last given days.
+// Days without any snippet are omitted.
+func (m *SnippetModel) CreatedPerDay(ctx context.Context, days int) (_ []*models.DailyCount, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.CreatedPerDay")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT DATE(created) AS day, COUNT(*) FROM snippets
+		WHERE created >= DATE_SUB(UTC_DATE(), INTERVAL ? DAY)
+		GROUP BY day ORDER BY day`
+	return queryDailyCounts(ctx, m.DB, stmt, days)
+}
+
+// ExpiredPerDay return the number of snippets expired on each of the last given days.
+// Days without any expiration are omitted.
+func (m *SnippetModel) ExpiredPerDay(ctx context.Context, days int) (_ []*models.DailyCount, err error) {
+	ctx, span := startSpan(ctx, "SnippetModel.ExpiredPerDay")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT DATE(expires) AS day, COUNT(*) FROM snippets
+		WHERE expires >= DATE_SUB(UTC_DATE(), INTERVAL ? DAY) AND expires <= UTC_TIMESTAMP()
+		GROUP BY day ORDER BY day`
+	return queryDailyCounts(ctx, m.DB, stmt, days)
+}
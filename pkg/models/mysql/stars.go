@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// StarModel is a wrapper of sql.DB connection pool toward the stars that users give to snippets.
+type StarModel struct {
+	DB *sql.DB
+	// Timeout bounds the queries of each method call, unless it is 0.
+	Timeout time.Duration
+}
+
+// Star stars the snippet for the user. Starring a snippet twice is a no-op.
+func (m *StarModel) Star(ctx context.Context, userID, snippetID int) (err error) {
+	ctx, span := startSpan(ctx, "StarModel.Star")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES(?, ?, UTC_TIMESTAMP())`
+	_, err = m.DB.ExecContext(ctx, stmt, userID, snippetID)
+	return err
+}
+
+// Unstar removes the star of the user from the snippet, if any.
+func (m *StarModel) Unstar(ctx context.Context, userID, snippetID int) (err error) {
+	ctx, span := startSpan(ctx, "StarModel.Unstar")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, userID, snippetID)
+	return err
+}
+
+// IsStarred return true if the user has starred the snippet.
+func (m *StarModel) IsStarred(ctx context.Context, userID, snippetID int) (starred bool, err error) {
+	ctx, span := startSpan(ctx, "StarModel.IsStarred")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = ? AND snippet_id = ?)`
+	err = m.DB.QueryRowContext(ctx, stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// Count return the number of stars on the snippet.
+func (m *StarModel) Count(ctx context.Context, snippetID int) (n int, err error) {
+	ctx, span := startSpan(ctx, "StarModel.Count")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM stars WHERE snippet_id = ?`, snippetID).Scan(&n)
+	return n, err
+}
+
+// ByUser return the snippets starred by the user which the user can still see,
+// the latest starred first.
+func (m *StarModel) ByUser(ctx context.Context, userID int) (_ []*models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "StarModel.ByUser")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires FROM stars st
+		JOIN snippets s ON s.id = st.snippet_id
+		WHERE st.user_id = ? AND s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE
+		AND (s.visibility <> 'private' OR s.user_id = st.user_id)
+		ORDER BY st.created DESC`
+	return querySnippets(ctx, m.DB, stmt, userID)
+}
+
+// MostStarredSince return the given number of public snippets which received the most
+// stars since the given time. The Stars field is the number of stars received since then.
+func (m *StarModel) MostStarredSince(ctx context.Context, since time.Time, limit int) (_ []*models.Snippet, err error) {
+	ctx, span := startSpan(ctx, "StarModel.MostStarredSince")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT s.id, s.title, s.content, s.created, s.expires, COUNT(*) AS n FROM stars st
+		JOIN snippets s ON s.id = st.snippet_id
+		WHERE st.created >= ? AND s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE
+		AND s.visibility = 'public'
+		GROUP BY s.id, s.title, s.content, s.created, s.expires ORDER BY n DESC, s.id DESC LIMIT ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Stars)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
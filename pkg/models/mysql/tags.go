@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// TagModel is a wrapper of sql.DB connection pool toward the tags in db.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Popular return the given number of tags used by the most public snippets,
+// in alphabetical order.
+func (m *TagModel) Popular(limit int) ([]*models.Tag, error) {
+	stmt := `SELECT name, n FROM (
+			SELECT t.name, COUNT(*) AS n FROM tags t
+			JOIN snippet_tags st ON st.tag_id = t.id JOIN snippets s ON s.id = st.snippet_id
+			WHERE s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE AND s.visibility = 'public'
+			GROUP BY t.name ORDER BY n DESC, t.name LIMIT ?
+		) popular ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		if err = rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
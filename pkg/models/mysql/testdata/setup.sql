@@ -1,12 +1,47 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    name VARCHAR(255) NOT NULL,
+    user_id INTEGER,
+    forked_from INTEGER,
+    title VARCHAR(100) NOT NULL,
     content TEXT NOT NULL,
+    content_type ENUM('text', 'markdown') NOT NULL DEFAULT 'text',
+    revision INTEGER NOT NULL DEFAULT 1,
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
     created DATETIME NOT NULL,
-    expires DATeTIME NOT NULL
+    expires DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    expiry_notified BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position),
+    CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
 
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
@@ -14,14 +49,107 @@ CREATE TABLE users (
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
     created DATETIME NOT NULL,
-    active BOOLEAN NOT NULL DEFAULT TRUE
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    must_reset_password BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_eamil UNIQUE (email);
 
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_stars_snippet_created ON stars(snippet_id, created);
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    body TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comments_snippet_created ON comments(snippet_id, created);
+
 INSERT INTO users (name, email, hashed_password, created) VALUES (
     'Alice Jones',
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2021-11-21 17:08:00'
-);
\ No newline at end of file
+);
+
+CREATE TABLE audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    actor_id INTEGER,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    visibility ENUM('public', 'private') NOT NULL DEFAULT 'public',
+    created DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id),
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day),
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhooks (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    status ENUM('pending', 'delivered', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt DATETIME NOT NULL,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt);
+CREATE INDEX idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created);
@@ -1,2 +1,13 @@
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
+DROP TABLE snippet_views;
+DROP TABLE collection_snippets;
+DROP TABLE collections;
+DROP TABLE comments;
+DROP TABLE stars;
+DROP TABLE audit_events;
 DROP TABLE users;
+DROP TABLE snippet_tags;
+DROP TABLE tags;
+DROP TABLE snippet_files;
 DROP TABLE snippets;
\ No newline at end of file
@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the model methods, with the tracer provider set by the
+// application, or none.
+var tracer = otel.Tracer("kerseeeHuang.com/snippetbox/pkg/models/mysql")
+
+// startSpan starts the span of a model method, named like "SnippetModel.Get", as a child
+// of the span in ctx.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL))
+}
+
+// endSpan ends the span of a model method which returned *err. Missing records and
+// invalid credentials are answers rather than failures, so they are not errors.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, models.ErrNoRecord) && !errors.Is(*err, models.ErrInvalidCredentials) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
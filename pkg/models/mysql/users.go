@@ -1,9 +1,13 @@
 package mysql
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"strings"
+	"time"
 
 	"kerseeeHuang.com/snippetbox/pkg/models"
 
@@ -14,14 +18,21 @@ import (
 // UserModel is a wrapper of sql.db connection pool toward the users table in db.
 type UserModel struct {
 	DB *sql.DB
+	// Timeout bounds the queries of each method call, unless it is 0.
+	Timeout time.Duration
 }
 
-// Insert insert an user into db if given user info are all valid.
-func (m *UserModel) Insert(name, email, password string) error {
+// Insert insert an user into db if given user info are all valid, and return its id.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Insert")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	// Hash the password.
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Prepare the statement.
@@ -29,28 +40,38 @@ func (m *UserModel) Insert(name, email, password string) error {
 		VALUES(?, ?, ?, UTC_TIMESTAMP())`
 
 	// Execute the statement and handle errors if any.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword))
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return models.ErrDuplicateEmail
+				return 0, models.ErrDuplicateEmail
 			}
 		}
-		return err
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return int(id), nil
 }
 
 // Authenticate authenticates the email addres and password, and return id
 // if it pass the verification.
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Authenticate")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	// Retrive id and hashed password with given email.
 	var id int
 	var hashedPassword []byte
 	stmt := "SELECT id, hashed_password FROM users WHERE email = ? AND active = TRUE"
-	row := m.DB.QueryRow(stmt, email)
-	err := row.Scan(&id, &hashedPassword)
+	row := m.DB.QueryRowContext(ctx, stmt, email)
+	err = row.Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, models.ErrInvalidCredentials
@@ -74,11 +95,18 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 // Get return the user detail based on given user id.
-func (m *UserModel) Get(id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Get")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	u := &models.User{}
 
-	stmt := `SELECT id, name, email, created, active FROM users WHERE id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	stmt := `SELECT id, name, email, created, active, admin, must_reset_password
+		FROM users WHERE id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active,
+		&u.Admin, &u.MustResetPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrNoRecord
@@ -92,11 +120,16 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 
 // ChangePassword update the password of the user in the DB given id,
 // if the id and currentPassword are all valid.
-func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.ChangePassword")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	// Retrieve the origin hashed_password of this user
 	var orgHashedPassword []byte
 	stmt := `SELECT hashed_password FROM users WHERE id = ?`
-	err := m.DB.QueryRow(stmt, id).Scan(&orgHashedPassword)
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&orgHashedPassword)
 	if err != nil {
 		return err
 	}
@@ -117,8 +150,106 @@ func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string)
 		return err
 	}
 
-	// Update the password to newPassword.
-	stmt = `UPDATE users SET hashed_password = ? WHERE id = ?`
-	_, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+	// Update the password to newPassword, which also fulfills any reset forced by an admin.
+	stmt = `UPDATE users SET hashed_password = ?, must_reset_password = FALSE WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
 	return err
 }
+
+// Search return a page of users whose name or email contains the given query,
+// together with the total number of matching users. An empty query matches all users.
+func (m *UserModel) Search(ctx context.Context, query string, limit, offset int) (_ []*models.User, _ int, err error) {
+	ctx, span := startSpan(ctx, "UserModel.Search")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	pattern := "%" + escapeLike(query) + "%"
+
+	var total int
+	stmt := `SELECT COUNT(*) FROM users WHERE name LIKE ? OR email LIKE ?`
+	err = m.DB.QueryRowContext(ctx, stmt, pattern, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt = `SELECT id, name, email, created, active, admin, must_reset_password
+		FROM users WHERE name LIKE ? OR email LIKE ? ORDER BY id LIMIT ? OFFSET ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, pattern, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		u := &models.User{}
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.Admin, &u.MustResetPassword)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// SetActive activates or deactivates the user with given id. Deactivated users
+// can neither login nor stay logged in.
+func (m *UserModel) SetActive(ctx context.Context, id int, active bool) (err error) {
+	ctx, span := startSpan(ctx, "UserModel.SetActive")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE users SET active = ? WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, active, id)
+	return err
+}
+
+// RequirePasswordReset replaces the password of the user with given id by a
+// random temporary one, and forces the user to change it before using the site
+// again. It return the temporary password, so that the admin can pass it on.
+func (m *UserModel) RequirePasswordReset(ctx context.Context, id int) (_ string, err error) {
+	ctx, span := startSpan(ctx, "UserModel.RequirePasswordReset")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// The old password stops working, in case it is the reason of the reset.
+	b := make([]byte, 12)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `UPDATE users SET hashed_password = ?, must_reset_password = TRUE WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, string(hashedPassword), id)
+	if err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// SignupsPerDay return the number of users signed up on each of the last given days.
+// Days without any signup are omitted.
+func (m *UserModel) SignupsPerDay(ctx context.Context, days int) (_ []*models.DailyCount, err error) {
+	ctx, span := startSpan(ctx, "UserModel.SignupsPerDay")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT DATE(created) AS day, COUNT(*) FROM users
+		WHERE created >= DATE_SUB(UTC_DATE(), INTERVAL ? DAY)
+		GROUP BY day ORDER BY day`
+	return queryDailyCounts(ctx, m.DB, stmt, days)
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -55,10 +56,10 @@ func TestUserModelGet(t *testing.T) {
 			defer teardown()
 
 			// Initialize a userModel.
-			m := UserModel{db}
+			m := UserModel{DB: db}
 
 			// Test the test case.
-			user, err := m.Get(test.userID)
+			user, err := m.Get(context.Background(), test.userID)
 			if err != test.wantError {
 				t.Errorf("want %v; got %v", test.wantError, err)
 			}
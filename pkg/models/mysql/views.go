@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// ViewModel is a wrapper of sql.DB connection pool toward the daily numbers of views of snippets.
+type ViewModel struct {
+	DB *sql.DB
+	// Timeout bounds the queries of each method call, unless it is 0.
+	Timeout time.Duration
+}
+
+// Add adds the given numbers of views, keyed by snippet id, to the views of the snippets
+// on the day. The views of snippets which have been deleted meanwhile are dropped.
+func (m *ViewModel) Add(ctx context.Context, day time.Time, counts map[int]int) (err error) {
+	if len(counts) == 0 {
+		return nil
+	}
+	ctx, span := startSpan(ctx, "ViewModel.Add")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	// Write all the counts in a single statement. IGNORE skips the deleted snippets
+	// instead of failing the whole batch on the foreign key.
+	values := make([]string, 0, len(counts))
+	args := make([]interface{}, 0, 3*len(counts))
+	for id, n := range counts {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, id, day.UTC().Format("2006-01-02"), n)
+	}
+	stmt := `INSERT IGNORE INTO snippet_views (snippet_id, day, views) VALUES ` + strings.Join(values, ", ") +
+		` ON DUPLICATE KEY UPDATE views = views + VALUES(views)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, args...)
+	return err
+}
+
+// Total return the number of views of the snippet.
+func (m *ViewModel) Total(ctx context.Context, snippetID int) (n int, err error) {
+	ctx, span := startSpan(ctx, "ViewModel.Total")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT COALESCE(SUM(views), 0) FROM snippet_views WHERE snippet_id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, snippetID).Scan(&n)
+	return n, err
+}
+
+// PerDay return the number of views of the snippet on each of the last given days.
+// Days without any view are omitted.
+func (m *ViewModel) PerDay(ctx context.Context, snippetID, days int) (_ []*models.DailyCount, err error) {
+	ctx, span := startSpan(ctx, "ViewModel.PerDay")
+	defer endSpan(span, &err)
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT day, views FROM snippet_views
+		WHERE snippet_id = ? AND day >= DATE_SUB(UTC_DATE(), INTERVAL ? DAY) ORDER BY day`
+	return queryDailyCounts(ctx, m.DB, stmt, snippetID, days)
+}
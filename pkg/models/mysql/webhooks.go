@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"kerseeeHuang.com/snippetbox/pkg/models"
+)
+
+// WebhookModel is a wrapper of sql.DB connection pool toward the webhooks and their
+// outbox of deliveries.
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Insert registers a new webhook of the user and return its id.
+func (m *WebhookModel) Insert(userID int, url, secret string) (int, error) {
+	stmt := `INSERT INTO webhooks (user_id, url, secret, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, userID, url, secret)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Get return a specific webhook based on given id.
+func (m *WebhookModel) Get(id int) (*models.Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, created FROM webhooks WHERE id = ?`
+
+	h := &models.Webhook{}
+	err := m.DB.QueryRow(stmt, id).Scan(&h.ID, &h.UserID, &h.URL, &h.Secret, &h.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return h, nil
+}
+
+// ByUser return all the webhooks of the user, oldest first.
+func (m *WebhookModel) ByUser(userID int) ([]*models.Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, created FROM webhooks WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		h := &models.Webhook{}
+		err = rows.Scan(&h.ID, &h.UserID, &h.URL, &h.Secret, &h.Created)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Delete deletes a specific webhook together with its deliveries.
+func (m *WebhookModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	return err
+}
+
+// Enqueue adds a pending delivery of the event with given payload to each webhook of
+// the user. The deliveries are due immediately.
+func (m *WebhookModel) Enqueue(userID int, event string, payload []byte) error {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt, created, updated)
+		SELECT id, ?, ?, 'pending', UTC_TIMESTAMP(), UTC_TIMESTAMP(), UTC_TIMESTAMP()
+		FROM webhooks WHERE user_id = ?`
+	_, err := m.DB.Exec(stmt, event, payload, userID)
+	return err
+}
+
+// Due return at most limit pending deliveries whose next attempt is not after now,
+// the most overdue first, with the URL and the secret of their webhooks.
+func (m *WebhookModel) Due(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	stmt := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status, d.attempts,
+		d.next_attempt, d.response_code, d.error, d.created, d.updated
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt <= ? ORDER BY d.next_attempt LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Status,
+			&d.Attempts, &d.NextAttempt, &d.ResponseCode, &d.Error, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt records an attempt of a specific delivery, which leaves it in the given
+// status with the response code and the error of the attempt. A pending delivery is
+// attempted again at next.
+func (m *WebhookModel) RecordAttempt(id int, status string, responseCode int, errMsg string, next time.Time) error {
+	stmt := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_code = ?,
+		error = ?, next_attempt = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := m.DB.Exec(stmt, status, responseCode, truncate(errMsg, 255), next.UTC(), id)
+	return err
+}
+
+// Deliveries return the latest limit deliveries of a specific webhook, newest first.
+func (m *WebhookModel) Deliveries(webhookID, limit int) ([]*models.WebhookDelivery, error) {
+	stmt := `SELECT id, webhook_id, event, payload, status, attempts, next_attempt, response_code, error,
+		created, updated FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created DESC, id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d := &models.WebhookDelivery{}
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt,
+			&d.ResponseCode, &d.Error, &d.Created, &d.Updated)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}